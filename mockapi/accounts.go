@@ -0,0 +1,40 @@
+package main
+
+import (
+	"slices"
+
+	"github.com/lacherogwu/ha-inim_cloud/mockapi/inimcloud"
+)
+
+// Fixtures can give devices an Account, the Username clients register with,
+// to check that an integration only sees the panels of its own account:
+//
+//	{"Devices": [{"DeviceId": 1, "Account": "alice", ...}, {"DeviceId": 2, "Account": "bob", ...}]}
+//
+// A device with an Account is only visible to sessions registered under that
+// Username. GetDevicesExtended leaves it out for anyone else, and calls
+// naming it answer ErrUnknownDevice, as for a device that doesn't exist.
+// Devices without an Account, such as the built-in ones, are visible to all.
+// Reads spanning devices other than GetDevicesExtended, such as the event
+// log, are not scoped.
+
+// RequestAccount returns the account of the caller's session, or "" when it
+// has none.
+func RequestAccount(reqData *inimcloud.Request) string {
+	session, _ := LookupSession(reqData.Token)
+	return session.Account
+}
+
+// VisibleTo reports whether callers of account may see the device.
+func (d Device) VisibleTo(account string) bool {
+	return d.Account == "" || d.Account == account
+}
+
+// AccountViews returns the DeviceViews of the devices account may see. The
+// caller must hold stateMu.
+func AccountViews(account string) []inimcloud.DeviceView {
+	return slices.DeleteFunc(DeviceViews(), func(view inimcloud.DeviceView) bool {
+		device, _ := store.Device(view.DeviceId)
+		return !device.VisibleTo(account)
+	})
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/lacherogwu/ha-inim_cloud/mockapi/inimcloud"
+)
+
+func TestAccountsSeeOnlyTheirDevices(t *testing.T) {
+	resetState()
+	alice, bob := defaultDevices[0], defaultDevices[0]
+	alice.Account = "alice"
+	bob.DeviceId, bob.Account = 545003, "bob"
+	shared := defaultDevices[0]
+	shared.DeviceId = 545004
+	store = NewMemoryStore([]Device{alice, bob, shared}, map[int]int{})
+	h := NewMux()
+
+	login := func(username string) string {
+		data := struct{ Token string }{}
+		mustCall(t, h, inimcloud.MethodRegisterClient, map[string]any{"Username": username, "ClientId": username}, &data)
+		return data.Token
+	}
+	devices := func(token string) []int {
+		_, reply := callApi(t, h, inimcloud.Request{Method: inimcloud.MethodGetDevicesExtended, Token: token})
+		data := struct{ Devices []inimcloud.DeviceView }{}
+		if err := json.Unmarshal(reply.Data, &data); err != nil {
+			t.Fatalf("GetDevicesExtended = %+v: %v", reply, err)
+		}
+		ids := []int{}
+		for _, device := range data.Devices {
+			ids = append(ids, device.DeviceId)
+		}
+		return ids
+	}
+	activateOn := func(token string, deviceId int) int {
+		_, reply := callApi(t, h, inimcloud.Request{Method: inimcloud.MethodActivateScenario, Token: token, Params: map[string]any{"DeviceId": deviceId, "ScenarioId": 2}})
+		return reply.Status
+	}
+
+	aliceToken, bobToken := login("alice"), login("bob")
+	for _, tt := range []struct {
+		name  string
+		token string
+		own   int
+		other int
+	}{
+		{"alice", aliceToken, alice.DeviceId, bob.DeviceId},
+		{"bob", bobToken, bob.DeviceId, alice.DeviceId},
+	} {
+		if got := devices(tt.token); len(got) != 2 || got[0] != tt.own || got[1] != shared.DeviceId {
+			t.Errorf("%s sees devices %v, want %d and the shared %d", tt.name, got, tt.own, shared.DeviceId)
+		}
+		if status := activateOn(tt.token, tt.own); status != 0 {
+			t.Errorf("%s activating its own device = %d", tt.name, status)
+		}
+		if status := activateOn(tt.token, tt.other); status != int(ErrUnknownDevice) {
+			t.Errorf("%s activating device %d of the other account = %d, want %d", tt.name, tt.other, status, ErrUnknownDevice)
+		}
+	}
+	if got := devices(""); len(got) != 1 || got[0] != shared.DeviceId {
+		t.Errorf("a caller without a session sees %v, want only the shared device", got)
+	}
+}
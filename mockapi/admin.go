@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/lacherogwu/ha-inim_cloud/mockapi/inimcloud"
+)
+
+// Snapshot is the full mutable state of the mock, as served by
+// /admin/snapshot and accepted by /admin/restore: the store, the sessions
+// and the simulation settings changed at runtime, down to how far each
+// behavior script has got. Two things are left out. Running exit delays are
+// cancelled, as the actor they would record isn't kept, and the poll
+// interval hints start over at -poll-min, as after any change. Propagation
+// delays restart for the same reason. What only comes from flags and
+// fixture files is not state and stays as the process started.
+type Snapshot struct {
+	StoreState
+	Sequence    int64                                `json:"Sequence"`
+	Sessions    []Session                            `json:"Sessions"`
+	FaultRules  []FaultRule                          `json:"FaultRules"`
+	Outage      []inimcloud.Method                   `json:"Outage"`
+	Maintenance bool                                 `json:"Maintenance"`
+	Behavior    map[inimcloud.Method][]*BehaviorStep `json:"Behavior"`
+}
+
+func TakeSnapshot() Snapshot {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+
+	return Snapshot{
+		StoreState:  store.Snapshot(),
+		Sequence:    sequence.Load(),
+		Sessions:    SnapshotSessions(),
+		FaultRules:  currentFaultRules(),
+		Outage:      currentOutage().Methods,
+		Maintenance: maintenance.Load(),
+		Behavior:    currentBehavior(),
+	}
+}
+
+// RestoreSnapshot overwrites the current state with snap. Commands that were
+// still pending when the snapshot was taken are scheduled again, as are the
+// completions of firmware updates in progress. Nothing is
+// changed when snap holds an invalid fault rule, behavior script or outage.
+func RestoreSnapshot(snap Snapshot) error {
+	for i := range snap.FaultRules {
+		if err := snap.FaultRules[i].validate(); err != nil {
+			return err
+		}
+	}
+	if err := compileBehavior(snap.Behavior); err != nil {
+		return err
+	}
+	if unknown := UnknownMethods(snap.Outage); len(unknown) > 0 {
+		return fmt.Errorf("unknown outage methods %v", unknown)
+	}
+
+	stateMu.Lock()
+	defer stateMu.Unlock()
+
+	store.Restore(snap.StoreState)
+	restores++
+	propagations = map[int]*propagation{}
+	sequence.Store(snap.Sequence)
+	eventSequence = 0
+	if log := store.Events(); len(log) > 0 {
+		eventSequence = log[len(log)-1].EventId
+	}
+	for _, cmd := range snap.Commands {
+		if cmd.Status == CommandPending {
+			scheduleConfirmation(cmd)
+		}
+	}
+	for deviceId, update := range snap.FirmwareUpdates {
+		scheduleFirmwareCompletion(deviceId, update)
+	}
+
+	polls = map[string]*pollState{}
+
+	RestoreSessions(snap.Sessions)
+	SetFaultRules(snap.FaultRules)
+	SetOutage(snap.Outage)
+	maintenance.Store(snap.Maintenance)
+	SetBehavior(cloneOrEmpty(snap.Behavior))
+	return nil
+}
+
+func HandleSnapshot(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(TakeSnapshot()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func HandleRestore(w http.ResponseWriter, r *http.Request) {
+	snap := Snapshot{}
+	if err := json.NewDecoder(r.Body).Decode(&snap); err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid snapshot")
+		return
+	}
+
+	if err := RestoreSnapshot(snap); err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid snapshot: "+err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
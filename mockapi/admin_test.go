@@ -0,0 +1,183 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/lacherogwu/ha-inim_cloud/mockapi/inimcloud"
+)
+
+func snapshot(t *testing.T, h http.Handler) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/snapshot", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /admin/snapshot = %d %s", rec.Code, rec.Body)
+	}
+	return rec.Body.String()
+}
+
+func TestSnapshotRestoreRoundTrip(t *testing.T) {
+	h := newTestMux(t)
+	mustCall(t, h, inimcloud.MethodActivateScenario, activate(2), nil)
+	post(t, h, "/admin/devices/545002/faults", `{"Type":"Tamper"}`)
+	post(t, h, "/admin/devices/545002/zones/1/open", "")
+	session := struct{ Token string }{}
+	mustCall(t, h, inimcloud.MethodRegisterClient, map[string]any{"ClientId": "ha-1"}, &session)
+	before := getDevice(t, h)
+	snap := snapshot(t, h)
+
+	h = newTestMux(t)
+	if rec := post(t, h, "/admin/restore", snap); rec.Code != http.StatusNoContent {
+		t.Fatalf("POST /admin/restore = %d %s", rec.Code, rec.Body)
+	}
+
+	after := getDevice(t, h)
+	if after.ActiveScenario != 2 || after.Version != before.Version || after.Zones[0].Status != inimcloud.ZoneOpen {
+		t.Errorf("restored device = %+v, want %+v", after, before)
+	}
+	faults := struct{ Devices []struct{ Faults []Fault } }{}
+	mustCall(t, h, inimcloud.MethodGetFaults, map[string]any{"DeviceId": testDevice}, &faults)
+	if len(faults.Devices[0].Faults) != 1 {
+		t.Errorf("restored faults = %+v", faults)
+	}
+	history := struct{ History []HistoryEntry }{}
+	mustCall(t, h, inimcloud.MethodGetScenarioHistory, map[string]any{"DeviceId": testDevice}, &history)
+	if len(history.History) != 1 {
+		t.Errorf("restored history = %+v", history)
+	}
+	if snapshot(t, h) != snap {
+		t.Error("snapshot after restore differs from the one restored")
+	}
+	_, reply := callApi(t, h, inimcloud.Request{Method: inimcloud.MethodAuthenticate, Token: session.Token})
+	if reply.Status != 0 {
+		t.Errorf("Authenticate with a token from before the restore = %+v", reply)
+	}
+
+	events := struct{ Events []Event }{}
+	mustCall(t, h, inimcloud.MethodGetEventsLatest, nil, &events)
+	last := events.Events[len(events.Events)-1].EventId
+	mustCall(t, h, inimcloud.MethodActivateScenario, activate(0), nil)
+	mustCall(t, h, inimcloud.MethodGetEventsLatest, map[string]any{"AfterId": last}, &events)
+	if len(events.Events) != 1 || events.Events[0].EventId != last+1 {
+		t.Errorf("events after the restore = %+v, want EventId %d", events.Events, last+1)
+	}
+}
+
+func TestRestoreReschedulesPendingCommands(t *testing.T) {
+	fake := useFakeClock(t)
+	setFlag(t, asyncActivation, true)
+	setFlag(t, activationDelay, time.Second)
+	h := newTestMux(t)
+
+	cmd := Command{}
+	mustCall(t, h, inimcloud.MethodActivateScenario, activate(0), &cmd)
+	snap := snapshot(t, h)
+
+	h = newTestMux(t)
+	post(t, h, "/admin/restore", snap)
+	fake.Advance(time.Second)
+
+	status := Command{}
+	mustCall(t, h, inimcloud.MethodGetCommandStatus, map[string]any{"CommandId": cmd.CommandId}, &status)
+	if status.Status != CommandDone {
+		t.Errorf("restored command = %s, want done", status.Status)
+	}
+	if got := getDevice(t, h).ActiveScenario; got != 0 {
+		t.Errorf("scenario = %d, want 0", got)
+	}
+}
+
+func TestRestoreRejectsInvalidSnapshot(t *testing.T) {
+	h := newTestMux(t)
+	if rec := post(t, h, "/admin/restore", "{"); rec.Code != http.StatusBadRequest {
+		t.Errorf("POST /admin/restore with invalid JSON = %d", rec.Code)
+	}
+}
+
+func TestRestoreKeepsSimulationState(t *testing.T) {
+	fake := useFakeClock(t)
+	setFlag(t, commandCooldown, time.Minute)
+	h := newTestMux(t)
+	mustCall(t, h, inimcloud.MethodActivateScenario, activate(2), nil)
+	post(t, h, "/admin/devices/545002/firmware-update", `{"Version":"6.08","Duration":"10s"}`)
+	post(t, h, "/admin/faults", `{"Method":"GetFaults","LatencyMs":20}`)
+	post(t, h, "/admin/outage", `{"Methods":["GetScenarioHistory"]}`)
+	post(t, h, "/admin/maintenance", `{"ReadOnly":true}`)
+	if err := loadBehavior(t, `{"GetSystemTime": [{"Error": "ErrMaintenance", "Times": 2}]}`); err != nil {
+		t.Fatal(err)
+	}
+	callStatus(t, h, inimcloud.MethodGetSystemTime, nil)
+	fake.Advance(5 * time.Second)
+	snap := snapshot(t, h)
+
+	h = newTestMux(t)
+	if rec := post(t, h, "/admin/restore", snap); rec.Code != http.StatusNoContent {
+		t.Fatalf("POST /admin/restore = %d %s", rec.Code, rec.Body)
+	}
+
+	if got := getDevice(t, h).Firmware; !got.Updating || got.Progress != 50 {
+		t.Errorf("restored firmware = %+v, want the update halfway", got)
+	}
+	if rules := currentFaultRules(); len(rules) != 1 || rules[0].LatencyMs != 20 {
+		t.Errorf("restored fault rules = %+v", rules)
+	}
+	if !InOutage(inimcloud.MethodGetScenarioHistory) || !maintenance.Load() {
+		t.Errorf("restored outage %v and maintenance %v, want both on", currentOutage().Methods, maintenance.Load())
+	}
+	for i, want := range []int{int(ErrMaintenance), 0} {
+		if status := callStatus(t, h, inimcloud.MethodGetSystemTime, nil); status != want {
+			t.Errorf("scripted call %d after the restore = %d, want %d", i+1, status, want)
+		}
+	}
+
+	post(t, h, "/admin/maintenance", `{"ReadOnly":false}`)
+	fake.Advance(5 * time.Second)
+	if got := getDevice(t, h).Firmware; got.Updating || got.Version != "6.08" {
+		t.Errorf("firmware once the restored update is due = %+v, want 6.08 installed", got)
+	}
+	if status := callStatus(t, h, inimcloud.MethodActivateScenario, activate(1)); status != int(ErrCommandTooSoon) {
+		t.Errorf("activation within the restored cooldown = %d, want %d", status, ErrCommandTooSoon)
+	}
+}
+
+func TestRestoreLeavesOutExitDelaysAndPollHints(t *testing.T) {
+	setFlag(t, exitDelayArming, true)
+	setFlag(t, pollMin, 5*time.Second)
+	c := useFakeClock(t)
+	h := newTestMux(t)
+	mustCall(t, h, inimcloud.MethodActivateScenario, activate(0), nil)
+	pollHint(t, h)
+	pollHint(t, h)
+	snap := snapshot(t, h)
+
+	if rec := post(t, h, "/admin/restore", snap); rec.Code != http.StatusNoContent {
+		t.Fatalf("POST /admin/restore = %d %s", rec.Code, rec.Body)
+	}
+	if got := pollHint(t, h); got != "5" {
+		t.Errorf("hint after the restore = %q, want 5", got)
+	}
+	c.Advance(time.Minute)
+	if device := getDevice(t, h); device.ExitDelay != nil || device.ActiveScenario != 1 {
+		t.Errorf("after the restore: exit delay %+v, scenario %d, want the countdown cancelled", device.ExitDelay, device.ActiveScenario)
+	}
+}
+
+func TestRestoreRejectsInvalidSettings(t *testing.T) {
+	h := newTestMux(t)
+	mustCall(t, h, inimcloud.MethodActivateScenario, activate(2), nil)
+	for _, snap := range []string{
+		`{"ActiveScenario":{"545002":0},"FaultRules":[{"Method":"Nope"}]}`,
+		`{"ActiveScenario":{"545002":0},"Behavior":{"GetSystemTime":[{"Delay":"soon"}]}}`,
+		`{"ActiveScenario":{"545002":0},"Outage":["GetSytemTime"]}`,
+	} {
+		if rec := post(t, h, "/admin/restore", snap); rec.Code != http.StatusBadRequest {
+			t.Errorf("POST /admin/restore %s = %d, want 400", snap, rec.Code)
+		}
+	}
+	if got := getDevice(t, h).ActiveScenario; got != 2 {
+		t.Errorf("scenario after the refused restores = %d, want 2", got)
+	}
+}
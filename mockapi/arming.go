@@ -0,0 +1,90 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/lacherogwu/ha-inim_cloud/mockapi/inimcloud"
+)
+
+// With -exit-delay, arming is not instantaneous: activating any scenario
+// other than a device's DisarmScenarios starts the device's ExitDelay
+// countdown, and the scenario only takes effect once it runs out. Meanwhile
+// the device reports the pending ExitDelay. Disarming, or arming again,
+// during the countdown cancels it, as does CancelActivation.
+var exitDelayArming = flag.Bool("exit-delay", false, "count down the device's exit delay before an arming scenario takes effect")
+
+// Activate applies a scenario change, going through the exit delay when it
+// arms the device. It returns the delay before the scenario takes effect.
+// The caller must hold stateMu.
+func Activate(device Device, scenarioId int, actor string) time.Duration {
+	deviceId := device.DeviceId
+	store.ClearExitDelay(deviceId)
+
+	delay := time.Duration(DeviceDelays(deviceId).ExitDelay) * time.Second
+	if !*exitDelayArming || delay == 0 || slices.Contains(device.DisarmScenarios, scenarioId) {
+		SetActiveScenario(deviceId, scenarioId, actor)
+		return 0
+	}
+
+	pending := inimcloud.ExitDelayView{ScenarioId: scenarioId, EndsAt: clock.Now().Add(delay)}
+	store.SetExitDelay(deviceId, pending)
+	store.MarkChanged(deviceId)
+	RecordEvent(Event{DeviceId: deviceId, Type: EventExitDelayStarted, ScenarioId: &scenarioId})
+
+	clock.AfterFunc(delay, func() {
+		stateMu.Lock()
+		defer stateMu.Unlock()
+
+		// The countdown may have been cancelled or replaced since.
+		current, ok := store.ExitDelay(deviceId)
+		if !ok || current.ScenarioId != pending.ScenarioId || !current.EndsAt.Equal(pending.EndsAt) {
+			return
+		}
+		store.ClearExitDelay(deviceId)
+		SetActiveScenario(deviceId, scenarioId, actor)
+	})
+	return delay
+}
+
+// PendingExitDelay returns the device's running countdown, if any. The
+// caller must hold stateMu.
+func PendingExitDelay(deviceId int) *inimcloud.ExitDelayView {
+	pending, ok := store.ExitDelay(deviceId)
+	if !ok {
+		return nil
+	}
+	return &pending
+}
+
+// HandleCancelActivation aborts the device's running exit delay. The device
+// keeps the scenario it had before the countdown started.
+func HandleCancelActivation(w http.ResponseWriter, reqData *inimcloud.Request) {
+	v := validateParams(reqData.Params)
+	deviceId := v.Int("DeviceId")
+	if !v.Check(w) {
+		return
+	}
+	stateMu.Lock()
+	defer stateMu.Unlock()
+
+	if _, found := store.Device(deviceId); !found {
+		WriteStatus(w, ErrUnknownDevice, "Device not found")
+		return
+	}
+	pending, ok := store.ExitDelay(deviceId)
+	if !ok {
+		WriteStatus(w, ErrNothingPending, "No activation pending")
+		return
+	}
+	store.ClearExitDelay(deviceId)
+	store.MarkChanged(deviceId)
+	RecordEvent(Event{DeviceId: deviceId, Type: EventExitDelayCancelled, ScenarioId: &pending.ScenarioId})
+
+	WriteJson(w, map[string]any{
+		"ActiveScenario": store.ActiveScenario(deviceId),
+		"Version":        store.Version(deviceId),
+	})
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"net/http"
+	"os"
+
+	"github.com/lacherogwu/ha-inim_cloud/mockapi/inimcloud"
+)
+
+// RegisterClient accepts any credentials unless -credentials names a JSON
+// file of usernames and their passwords, e.g. {"alice": "s3cret"}. Other
+// checks can be plugged in by replacing credentialValidator.
+var credentialsFile = flag.String("credentials", "", "JSON file mapping usernames to passwords accepted by RegisterClient")
+
+// CredentialValidator decides whether a login is accepted.
+type CredentialValidator interface {
+	Validate(username, password string) bool
+}
+
+type acceptAllCredentials struct{}
+
+func (acceptAllCredentials) Validate(username, password string) bool {
+	return true
+}
+
+// StaticCredentials accepts the listed usernames with their password.
+type StaticCredentials map[string]string
+
+func (c StaticCredentials) Validate(username, password string) bool {
+	expected, ok := c[username]
+	return ok && expected == password
+}
+
+var credentialValidator CredentialValidator = acceptAllCredentials{}
+
+func LoadCredentials(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	credentials := StaticCredentials{}
+	if err := json.Unmarshal(data, &credentials); err != nil {
+		return err
+	}
+	credentialValidator = credentials
+	return nil
+}
+
+// CheckCredentials validates the Username and Password params, answering the
+// call with a 401 when they are refused.
+func CheckCredentials(w http.ResponseWriter, reqData *inimcloud.Request) bool {
+	username, _ := paramString(reqData.Params, "Username")
+	password, _ := paramString(reqData.Params, "Password")
+	if credentialValidator.Validate(username, password) {
+		return true
+	}
+	WriteStatusCode(w, http.StatusUnauthorized, ErrInvalidCredentials, "Invalid username or password")
+	return false
+}
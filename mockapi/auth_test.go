@@ -0,0 +1,64 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lacherogwu/ha-inim_cloud/mockapi/inimcloud"
+)
+
+func register(username, password string) inimcloud.Request {
+	return inimcloud.Request{Method: inimcloud.MethodRegisterClient, Params: map[string]any{
+		"Username": username,
+		"Password": password,
+		"ClientId": "test",
+	}}
+}
+
+func TestRegisterClientAcceptsAnyoneByDefault(t *testing.T) {
+	h := newTestMux(t)
+	data := struct{ Token string }{}
+	mustCall(t, h, inimcloud.MethodRegisterClient, register("anyone", "").Params, &data)
+	if data.Token == "" {
+		t.Error("RegisterClient returned no token")
+	}
+}
+
+func TestRegisterClientChecksCredentials(t *testing.T) {
+	h := newTestMux(t)
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	if err := os.WriteFile(path, []byte(`{"alice": "s3cret"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadCredentials(path); err != nil {
+		t.Fatal(err)
+	}
+
+	mustCall(t, h, inimcloud.MethodRegisterClient, register("alice", "s3cret").Params, nil)
+	for _, req := range []inimcloud.Request{register("alice", "wrong"), register("bob", "s3cret"), register("", "")} {
+		rec, reply := callApi(t, h, req)
+		if rec.Code != http.StatusUnauthorized || reply.Status != int(ErrInvalidCredentials) {
+			t.Errorf("RegisterClient(%v) = %d %s, want 401 with Status %d", req.Params, rec.Code, rec.Body, ErrInvalidCredentials)
+		}
+	}
+}
+
+type validatorFunc func(username, password string) bool
+
+func (f validatorFunc) Validate(username, password string) bool {
+	return f(username, password)
+}
+
+func TestCustomCredentialValidator(t *testing.T) {
+	h := newTestMux(t)
+	credentialValidator = validatorFunc(func(username, password string) bool {
+		return password == "key-"+username
+	})
+
+	mustCall(t, h, inimcloud.MethodRegisterClient, register("carol", "key-carol").Params, nil)
+	if rec, _ := callApi(t, h, register("carol", "key-dave")); rec.Code != http.StatusUnauthorized {
+		t.Errorf("refused login = %d, want 401", rec.Code)
+	}
+}
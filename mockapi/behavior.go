@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/lacherogwu/ha-inim_cloud/mockapi/inimcloud"
+)
+
+// A behavior script scripts the outcome of successive calls per method, for
+// example:
+//
+//	{"ActivateScenario": [
+//		{"Delay": "2s", "Error": "ErrRateLimited", "Times": 3},
+//		{"Times": 1}
+//	]}
+//
+// delays the next three activations by 2s and fails them as rate limited,
+// then lets one through. Each step applies to Times calls, or to every call
+// from then on when Times is 0. Once a method's steps are used up, it
+// behaves normally again. A step may fail with an API Error or with a plain
+// 5xx HttpStatus. Scripts naming a method the mock doesn't serve are refused.
+var behaviorFile = flag.String("behavior", "", "JSON file scripting per-method delays and failures")
+
+type BehaviorStep struct {
+	Delay      string `json:"Delay"`
+	Error      string `json:"Error"`
+	HttpStatus int    `json:"HttpStatus"`
+	Times      int    `json:"Times"`
+
+	delay time.Duration
+	code  ErrorCode
+}
+
+var (
+	behaviorMu sync.Mutex
+	behavior   = map[inimcloud.Method][]*BehaviorStep{}
+)
+
+func LoadBehavior(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	script := map[inimcloud.Method][]*BehaviorStep{}
+	if err := json.Unmarshal(data, &script); err != nil {
+		return err
+	}
+	if err := compileBehavior(script); err != nil {
+		return err
+	}
+
+	SetBehavior(script)
+	return nil
+}
+
+// compileBehavior checks a script and resolves its delays and error names.
+func compileBehavior(script map[inimcloud.Method][]*BehaviorStep) error {
+	var err error
+	for method, steps := range script {
+		if _, ok := handlers[method]; !ok {
+			return fmt.Errorf("unknown method %q", method)
+		}
+		for _, step := range steps {
+			if step.HttpStatus != 0 && (step.HttpStatus < 500 || step.HttpStatus > 599) {
+				return fmt.Errorf("%s: HttpStatus must be a 5xx", method)
+			}
+			if step.Delay != "" {
+				if step.delay, err = time.ParseDuration(step.Delay); err != nil {
+					return fmt.Errorf("%s: %w", method, err)
+				}
+			}
+			if step.Error != "" {
+				code, ok := errorNames[step.Error]
+				if !ok {
+					return fmt.Errorf("%s: unknown error %q", method, step.Error)
+				}
+				step.code = code
+			}
+		}
+	}
+	return nil
+}
+
+// currentBehavior returns the steps left of every method's script, with
+// the calls left of each.
+func currentBehavior() map[inimcloud.Method][]*BehaviorStep {
+	behaviorMu.Lock()
+	defer behaviorMu.Unlock()
+
+	script := make(map[inimcloud.Method][]*BehaviorStep, len(behavior))
+	for method, steps := range behavior {
+		for _, step := range steps {
+			left := *step
+			script[method] = append(script[method], &left)
+		}
+	}
+	return script
+}
+
+// SetBehavior replaces the script with one compileBehavior accepted.
+func SetBehavior(script map[inimcloud.Method][]*BehaviorStep) {
+	behaviorMu.Lock()
+	defer behaviorMu.Unlock()
+	behavior = script
+}
+
+// nextBehavior consumes one call from the current step of method's script,
+// if any is left.
+func nextBehavior(method inimcloud.Method) (BehaviorStep, bool) {
+	behaviorMu.Lock()
+	defer behaviorMu.Unlock()
+
+	steps := behavior[method]
+	if len(steps) == 0 {
+		return BehaviorStep{}, false
+	}
+
+	step := steps[0]
+	if step.Times > 0 {
+		step.Times--
+		if step.Times == 0 {
+			behavior[method] = steps[1:]
+		}
+	}
+	return *step, true
+}
+
+// ApplyBehavior plays the scripted step for the call, if any. It reports
+// true when the step answered the call itself.
+func ApplyBehavior(w http.ResponseWriter, method inimcloud.Method) bool {
+	step, ok := nextBehavior(method)
+	if !ok {
+		return false
+	}
+
+	clock.Sleep(step.delay)
+	switch {
+	case step.HttpStatus != 0:
+		WriteError(w, step.HttpStatus, http.StatusText(step.HttpStatus))
+		return true
+	case step.code != 0:
+		WriteStatus(w, step.code, step.Error)
+		return true
+	}
+	return false
+}
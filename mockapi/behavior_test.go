@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lacherogwu/ha-inim_cloud/mockapi/inimcloud"
+)
+
+func loadBehavior(t *testing.T, script string) error {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "behavior.json")
+	if err := os.WriteFile(path, []byte(script), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return LoadBehavior(path)
+}
+
+func TestBehaviorScriptSteps(t *testing.T) {
+	fake := useFakeClock(t)
+	h := newTestMux(t)
+	err := loadBehavior(t, `{"ActivateScenario": [
+		{"Delay": "2s", "Error": "ErrRateLimited", "Times": 2},
+		{"HttpStatus": 502, "Times": 1},
+		{"Times": 1}
+	]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range 2 {
+		if status := callStatus(t, h, inimcloud.MethodActivateScenario, activate(2)); status != int(ErrRateLimited) {
+			t.Errorf("call %d = %d, want %d", i+1, status, ErrRateLimited)
+		}
+		if slept := fake.Slept(); slept != 2*time.Second {
+			t.Errorf("call %d delayed %v, want 2s", i+1, slept)
+		}
+	}
+	if rec, _ := callApi(t, h, inimcloud.Request{Method: inimcloud.MethodActivateScenario, Params: activate(2)}); rec.Code != http.StatusBadGateway {
+		t.Errorf("third call = %d, want 502", rec.Code)
+	}
+	// A step without an outcome lets the call through, and once the script
+	// is used up the method behaves normally.
+	mustCall(t, h, inimcloud.MethodActivateScenario, activate(2), nil)
+	mustCall(t, h, inimcloud.MethodActivateScenario, activate(0), nil)
+	mustCall(t, h, inimcloud.MethodGetSystemTime, nil, nil)
+}
+
+func TestBehaviorStepWithoutTimesLasts(t *testing.T) {
+	useFakeClock(t)
+	h := newTestMux(t)
+	if err := loadBehavior(t, `{"GetSystemTime": [{"Error": "ErrMaintenance"}]}`); err != nil {
+		t.Fatal(err)
+	}
+	for range 5 {
+		if status := callStatus(t, h, inimcloud.MethodGetSystemTime, nil); status != int(ErrMaintenance) {
+			t.Fatalf("GetSystemTime = %d, want %d", status, ErrMaintenance)
+		}
+	}
+}
+
+func TestLoadBehaviorInvalid(t *testing.T) {
+	resetState()
+	for _, script := range []string{
+		`{"ActivateScenario": [{"Delay": "soon"}]}`,
+		`{"ActivateScenario": [{"Error": "ErrNope"}]}`,
+		`{"ActivateScenarios": [{"Error": "ErrMaintenance"}]}`,
+		`{"ActivateScenario": [{"HttpStatus": 404}]}`,
+		`{"ActivateScenario": [{"HttpStatus": 600}]}`,
+		`[]`,
+	} {
+		if err := loadBehavior(t, script); err == nil {
+			t.Errorf("LoadBehavior(%s) succeeded", script)
+		}
+	}
+}
@@ -0,0 +1,399 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lacherogwu/ha-inim_cloud/mockapi/inimcloud"
+)
+
+// clientServer serves the mock over HTTP for the inimcloud client, counting
+// the calls of each method.
+type clientServer struct {
+	*httptest.Server
+	mu    sync.Mutex
+	calls map[inimcloud.Method]int
+}
+
+func newClientServer(t *testing.T) *clientServer {
+	t.Helper()
+	s := &clientServer{calls: map[inimcloud.Method]int{}}
+	h := newTestMux(t)
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := inimcloud.Request{}
+		if json.Unmarshal([]byte(r.URL.Query().Get("req")), &req) == nil {
+			s.mu.Lock()
+			s.calls[req.Method]++
+			s.mu.Unlock()
+		}
+		h.ServeHTTP(w, r)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *clientServer) count(method inimcloud.Method) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[method]
+}
+
+// registeredClient returns a client of s that has logged in.
+func registeredClient(t *testing.T, s *clientServer, opts ...inimcloud.Option) *inimcloud.Client {
+	t.Helper()
+	c := inimcloud.NewClient(s.URL, opts...)
+	if _, err := c.RegisterClient(context.Background(), "user", "pass"); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestClientActivateAndRead(t *testing.T) {
+	s := newClientServer(t)
+	c := registeredClient(t, s)
+	ctx := context.Background()
+
+	activation, err := c.ActivateScenario(ctx, testDevice, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if activation.Sequence == 0 || activation.Version != 1 {
+		t.Errorf("activation = %+v", activation)
+	}
+	devices, err := c.GetDevicesExtended(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(devices) != 1 || devices[0].ActiveScenario != 2 || devices[0].ActiveScenarioName != "STAY" {
+		t.Errorf("devices = %+v, want %d in STAY", devices, testDevice)
+	}
+
+	if _, err := c.Authenticate(ctx); err != nil {
+		t.Errorf("Authenticate: %v", err)
+	}
+}
+
+func TestClientApiErrors(t *testing.T) {
+	s := newClientServer(t)
+	c := registeredClient(t, s)
+
+	_, err := c.ActivateScenario(context.Background(), testDevice, 9)
+	var apiErr *inimcloud.APIError
+	if !errors.As(err, &apiErr) || apiErr.Status != int(ErrUnknownScenario) || apiErr.StatusCode != http.StatusOK {
+		t.Errorf("unknown scenario: err = %v", err)
+	}
+
+	credentialValidator = StaticCredentials{"user": "other"}
+	_, err = inimcloud.NewClient(s.URL).RegisterClient(context.Background(), "user", "pass")
+	if !errors.As(err, &apiErr) || apiErr.Status != int(ErrInvalidCredentials) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("wrong password: err = %v", err)
+	}
+
+	SetOutage([]inimcloud.Method{inimcloud.MethodGetDevicesExtended})
+	_, err = c.GetDevicesExtended(context.Background())
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable || apiErr.Message != "Service unavailable" {
+		t.Errorf("outage: err = %v", err)
+	}
+}
+
+func TestClientRegistersAgainWhenTheTokenExpires(t *testing.T) {
+	setFlag(t, requireToken, true)
+	setFlag(t, tokenTTL, 30*time.Second)
+	fake := useFakeClock(t)
+	s := newClientServer(t)
+	c := registeredClient(t, s)
+
+	fake.Advance(time.Minute)
+	if _, err := c.GetDevicesExtended(context.Background()); err != nil {
+		t.Fatalf("call with an expired token: %v", err)
+	}
+	if n := s.count(inimcloud.MethodRegisterClient); n != 2 {
+		t.Errorf("RegisterClient called %d times, want 2", n)
+	}
+	if n := s.count(inimcloud.MethodGetDevicesExtended); n != 2 {
+		t.Errorf("GetDevicesExtended called %d times, want the refused call and its retry", n)
+	}
+}
+
+func TestClientSurvivesFlakyReplies(t *testing.T) {
+	setFlag(t, flakyRate, 1)
+	reseed(t, 3)
+	s := newClientServer(t)
+	c := registeredClient(t, s)
+	for range 20 {
+		if _, err := c.GetDevicesExtended(context.Background()); err != nil {
+			t.Fatalf("flaky reply: %v", err)
+		}
+	}
+}
+
+func TestClientHonorsContext(t *testing.T) {
+	s := newClientServer(t)
+	c := registeredClient(t, s)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := c.GetDevicesExtended(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled call: err = %v", err)
+	}
+}
+
+func TestClientCache(t *testing.T) {
+	s := newClientServer(t)
+	c := registeredClient(t, s, inimcloud.WithCache(time.Minute))
+	ctx := context.Background()
+
+	for range 3 {
+		if _, err := c.GetDevicesExtended(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := s.count(inimcloud.MethodGetDevicesExtended); n != 1 {
+		t.Errorf("3 reads within the TTL reached the server %d times, want 1", n)
+	}
+
+	if _, err := c.ActivateScenario(ctx, testDevice, 2); err != nil {
+		t.Fatal(err)
+	}
+	devices, err := c.GetDevicesExtended(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := s.count(inimcloud.MethodGetDevicesExtended); n != 2 || devices[0].ActiveScenario != 2 {
+		t.Errorf("read after activating: %d server reads, scenario %d, want 2 and 2", n, devices[0].ActiveScenario)
+	}
+}
+
+func TestDevicesIteratorPages(t *testing.T) {
+	s := newClientServer(t)
+	store = NewMemoryStore(manyDevices(7))
+	c := registeredClient(t, s)
+
+	ids := []int{}
+	for device, err := range c.DevicesIterator(context.Background(), 3) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, device.DeviceId)
+	}
+	if !slices.Equal(ids, []int{1, 2, 3, 4, 5, 6, 7}) {
+		t.Errorf("iterated %v, want devices 1 to 7", ids)
+	}
+	if n := s.count(inimcloud.MethodGetDevicesExtended); n != 3 {
+		t.Errorf("%d pages fetched, want 3", n)
+	}
+}
+
+func TestDevicesIteratorStopsEarly(t *testing.T) {
+	s := newClientServer(t)
+	store = NewMemoryStore(manyDevices(7))
+	c := registeredClient(t, s)
+
+	seen := 0
+	for _, err := range c.DevicesIterator(context.Background(), 3) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seen++; seen == 4 {
+			break
+		}
+	}
+	if n := s.count(inimcloud.MethodGetDevicesExtended); n != 2 {
+		t.Errorf("%d pages fetched after breaking at the 4th device, want 2", n)
+	}
+}
+
+func TestDevicesIteratorHonorsContext(t *testing.T) {
+	s := newClientServer(t)
+	store = NewMemoryStore(manyDevices(7))
+	c := registeredClient(t, s)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	seen := 0
+	var iterErr error
+	for _, err := range c.DevicesIterator(ctx, 3) {
+		if err != nil {
+			iterErr = err
+			continue
+		}
+		if seen++; seen == 3 {
+			cancel()
+		}
+	}
+	if seen != 3 || !errors.Is(iterErr, context.Canceled) {
+		t.Errorf("saw %d devices, err %v, want 3 then context.Canceled", seen, iterErr)
+	}
+}
+
+func TestStreamDevices(t *testing.T) {
+	s := newClientServer(t)
+	store = NewMemoryStore(manyDevices(1000))
+	c := inimcloud.NewClient(s.URL)
+
+	count, last := 0, 0
+	for device, err := range c.StreamDevices(context.Background()) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if device.DeviceId != last+1 {
+			t.Fatalf("device %d after %d", device.DeviceId, last)
+		}
+		count, last = count+1, device.DeviceId
+	}
+	if count != 1000 {
+		t.Errorf("streamed %d devices, want 1000", count)
+	}
+}
+
+func TestStreamDevicesStopsEarly(t *testing.T) {
+	s := newClientServer(t)
+	store = NewMemoryStore(manyDevices(1000))
+	c := inimcloud.NewClient(s.URL)
+
+	count := 0
+	for _, err := range c.StreamDevices(context.Background()) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count++; count == 10 {
+			break
+		}
+	}
+	if count != 10 {
+		t.Errorf("streamed %d devices, want to stop at 10", count)
+	}
+}
+
+func TestClientSigning(t *testing.T) {
+	setFlag(t, signingSecret, testSecret)
+	s := newClientServer(t)
+
+	c := registeredClient(t, s, inimcloud.WithSigning(testSecret))
+	if _, err := c.GetDevicesExtended(context.Background()); err != nil {
+		t.Errorf("signed call: %v", err)
+	}
+
+	_, err := inimcloud.NewClient(s.URL, inimcloud.WithSigning("wrong")).RegisterClient(context.Background(), "user", "pass")
+	var apiErr *inimcloud.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized || apiErr.Message != "Invalid signature" {
+		t.Errorf("call signed with the wrong secret: err = %v", err)
+	}
+	if _, err := inimcloud.NewClient(s.URL).RegisterClient(context.Background(), "user", "pass"); err == nil {
+		t.Error("unsigned call accepted")
+	}
+}
+
+func TestClientValidationError(t *testing.T) {
+	s := newClientServer(t)
+	c := registeredClient(t, s)
+
+	for _, err := range c.DevicesIterator(context.Background(), 0) {
+		var validationErr *inimcloud.ValidationError
+		if !errors.As(err, &validationErr) {
+			t.Fatalf("page of 0 devices: err = %v, want a ValidationError", err)
+		}
+		want := []inimcloud.FieldError{{Field: "Limit", Reason: "must be at least 1"}}
+		if validationErr.Method != inimcloud.MethodGetDevicesExtended || !slices.Equal(validationErr.Fields, want) {
+			t.Errorf("ValidationError = %+v, want %+v", validationErr, want)
+		}
+	}
+}
+
+func TestWaitForScenarioWaitsOutTheExitDelay(t *testing.T) {
+	setFlag(t, exitDelayArming, true)
+	clock := useFakeClock(t)
+	s := newClientServer(t)
+	mustCall(t, s.Config.Handler, inimcloud.MethodSetDelays, map[string]any{"DeviceId": testDevice, "ExitDelay": 10}, nil)
+	// The cache would keep serving the countdown; the wait must read past it.
+	c := registeredClient(t, s, inimcloud.WithPollInterval(time.Millisecond), inimcloud.WithCache(time.Hour))
+	ctx := context.Background()
+
+	if _, err := c.ActivateScenario(ctx, testDevice, 2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetDevicesExtended(ctx); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- c.WaitForScenario(ctx, testDevice, 2) }()
+
+	for s.count(inimcloud.MethodGetDevicesExtended) < 4 {
+		select {
+		case err := <-done:
+			t.Fatalf("returned %v during the exit delay", err)
+		case <-time.After(time.Millisecond):
+		}
+	}
+	clock.Advance(10 * time.Second)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("still waiting after the exit delay ran out")
+	}
+}
+
+func TestWaitForScenarioHonorsContext(t *testing.T) {
+	s := newClientServer(t)
+	c := registeredClient(t, s, inimcloud.WithPollInterval(time.Millisecond))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := c.WaitForScenario(ctx, testDevice, 2); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want context.DeadlineExceeded", err)
+	}
+	if err := c.WaitForScenario(context.Background(), 1, 1); err == nil {
+		t.Error("waiting on an unknown device succeeded")
+	}
+}
+
+func TestClientReusesConnections(t *testing.T) {
+	s := newClientServer(t)
+	transport := inimcloud.NewTransport()
+	dial := transport.DialContext
+	var dials atomic.Int32
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dials.Add(1)
+		return dial(ctx, network, addr)
+	}
+	defer transport.CloseIdleConnections()
+
+	const workers, calls = 8, 25
+	clients := []*inimcloud.Client{
+		registeredClient(t, s, inimcloud.WithTransport(transport)),
+		registeredClient(t, s, inimcloud.WithTransport(transport)),
+	}
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*calls)
+	for i := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c := clients[i%len(clients)]
+			for range calls {
+				if _, err := c.GetDevicesExtended(context.Background()); err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+	if n := dials.Load(); n > workers {
+		t.Errorf("%d calls dialed %d connections, want at most %d", workers*calls, n, workers)
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+import "time"
+
+// Clock is where the mock reads the time and schedules its timers, so tests
+// can swap in a fake one and step through delays instead of waiting them
+// out.
+type Clock interface {
+	Now() time.Time
+	AfterFunc(d time.Duration, f func()) Timer
+	Sleep(d time.Duration)
+}
+
+type Timer interface {
+	Stop() bool
+}
+
+var clock Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer { return time.AfterFunc(d, f) }
+
+func (realClock) Sleep(d time.Duration) { time.Sleep(d) }
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock stands still until the test advances it, firing the timers that
+// fall due on the way. Sleeping advances it too, and is tallied in slept.
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+	slept  time.Duration
+}
+
+type fakeTimer struct {
+	clock *fakeClock
+	at    time.Time
+	f     func()
+}
+
+// useFakeClock swaps in a fake clock for the duration of the test.
+func useFakeClock(t *testing.T) *fakeClock {
+	t.Helper()
+	fake := &fakeClock{now: time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)}
+	old := clock
+	clock = fake
+	t.Cleanup(func() { clock = old })
+	return fake
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) AfterFunc(d time.Duration, f func()) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	timer := &fakeTimer{clock: c, at: c.now.Add(d), f: f}
+	c.timers = append(c.timers, timer)
+	return timer
+}
+
+func (c *fakeClock) Sleep(d time.Duration) {
+	c.mu.Lock()
+	c.slept += d
+	c.mu.Unlock()
+	c.Advance(d)
+}
+
+// Slept returns the total time slept, and resets it.
+func (c *fakeClock) Slept() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	slept := c.slept
+	c.slept = 0
+	return slept
+}
+
+// Advance moves the clock forward by d, running due timers in order. The
+// timers run without the clock's lock, so they may use the clock.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	end := c.now.Add(d)
+	c.mu.Unlock()
+
+	for {
+		c.mu.Lock()
+		due := -1
+		for i, timer := range c.timers {
+			if !timer.at.After(end) && (due < 0 || timer.at.Before(c.timers[due].at)) {
+				due = i
+			}
+		}
+		if due < 0 {
+			c.now = end
+			c.mu.Unlock()
+			return
+		}
+		timer := c.timers[due]
+		c.timers = slices.Delete(c.timers, due, due+1)
+		c.now = timer.at
+		c.mu.Unlock()
+		timer.f()
+	}
+}
+
+func (timer *fakeTimer) Stop() bool {
+	c := timer.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	i := slices.Index(c.timers, timer)
+	if i < 0 {
+		return false
+	}
+	c.timers = slices.Delete(c.timers, i, i+1)
+	return true
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"flag"
+	"time"
+)
+
+// Async activation mimics the cloud accepting an ActivateScenario before the
+// panel has confirmed it: the call returns a CommandId straight away and the
+// scenario only changes once activationDelay has passed. Clients follow the
+// command with GetCommandStatus.
+var (
+	asyncActivation = flag.Bool("async-activation", false, "accept ActivateScenario asynchronously and confirm it after -activation-delay")
+	activationDelay = flag.Duration("activation-delay", 3*time.Second, "time the panel takes to confirm an async activation")
+)
+
+type CommandStatus string
+
+const (
+	CommandPending CommandStatus = "pending"
+	CommandDone    CommandStatus = "done"
+	CommandFailed  CommandStatus = "failed"
+)
+
+type Command struct {
+	CommandId  string        `json:"CommandId"`
+	DeviceId   int           `json:"DeviceId"`
+	ScenarioId int           `json:"ScenarioId"`
+	Status     CommandStatus `json:"Status"`
+	Sequence   int64         `json:"Sequence"`
+	Actor      string        `json:"Actor"`
+}
+
+// restores counts the snapshots restored, so confirmations scheduled before
+// a restore can tell their command was replaced. Guarded by stateMu.
+var restores int
+
+// QueueActivation registers a pending activation and schedules its
+// confirmation. The command fails if the device is unknown by then. The
+// caller must hold stateMu.
+func QueueActivation(deviceId, scenarioId int, actor string) Command {
+	cmd := store.AddCommand(Command{
+		DeviceId:   deviceId,
+		ScenarioId: scenarioId,
+		Status:     CommandPending,
+		Sequence:   NextSequence(),
+		Actor:      actor,
+	})
+	scheduleConfirmation(cmd)
+	return cmd
+}
+
+// scheduleConfirmation confirms cmd once the panel is done. The caller must
+// hold stateMu.
+func scheduleConfirmation(cmd Command) {
+	scheduledAt := restores
+	clock.AfterFunc(*activationDelay, func() {
+		stateMu.Lock()
+		defer stateMu.Unlock()
+
+		// The command table may have been replaced by a restore since.
+		if restores != scheduledAt {
+			return
+		}
+		device, known := store.Device(cmd.DeviceId)
+		if !known {
+			store.SetCommandStatus(cmd.CommandId, CommandFailed)
+			return
+		}
+		Activate(device, cmd.ScenarioId, cmd.Actor)
+		store.SetCommandStatus(cmd.CommandId, CommandDone)
+	})
+}
+
+// GetCommand returns the command with the given id.
+func GetCommand(commandId string) (Command, bool) {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	return store.Command(commandId)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/lacherogwu/ha-inim_cloud/mockapi/inimcloud"
+)
+
+func TestAsyncActivationConfirmsAfterDelay(t *testing.T) {
+	fake := useFakeClock(t)
+	setFlag(t, asyncActivation, true)
+	setFlag(t, activationDelay, 3*time.Second)
+	h := newTestMux(t)
+
+	cmd := Command{}
+	mustCall(t, h, inimcloud.MethodActivateScenario, activate(2), &cmd)
+	if cmd.CommandId == "" || cmd.Status != CommandPending {
+		t.Fatalf("ActivateScenario = %+v, want a pending command", cmd)
+	}
+	if got := getDevice(t, h).ActiveScenario; got != 1 {
+		t.Errorf("scenario before confirmation = %d, want 1", got)
+	}
+
+	poll := func() Command {
+		status := Command{}
+		mustCall(t, h, inimcloud.MethodGetCommandStatus, map[string]any{"CommandId": cmd.CommandId}, &status)
+		return status
+	}
+	fake.Advance(2 * time.Second)
+	if status := poll(); status.Status != CommandPending {
+		t.Errorf("status before the delay = %s, want pending", status.Status)
+	}
+	fake.Advance(time.Second)
+	if status := poll(); status.Status != CommandDone || status.Sequence != cmd.Sequence {
+		t.Errorf("status after the delay = %+v, want done with sequence %d", status, cmd.Sequence)
+	}
+	if got := getDevice(t, h).ActiveScenario; got != 2 {
+		t.Errorf("scenario after confirmation = %d, want 2", got)
+	}
+}
+
+func TestAsyncActivationValidatesUpFront(t *testing.T) {
+	useFakeClock(t)
+	setFlag(t, asyncActivation, true)
+	h := newTestMux(t)
+
+	if status := callStatus(t, h, inimcloud.MethodActivateScenario, activate(9)); status != int(ErrUnknownScenario) {
+		t.Errorf("unknown scenario = %d, want %d", status, ErrUnknownScenario)
+	}
+}
+
+func TestGetCommandStatusUnknownCommand(t *testing.T) {
+	h := newTestMux(t)
+	rec, reply := callApi(t, h, inimcloud.Request{Method: inimcloud.MethodGetCommandStatus, Params: map[string]any{"CommandId": "42"}})
+	if rec.Code != http.StatusBadRequest || reply.Error != "Unknown command" {
+		t.Errorf("unknown command = %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestSyncActivationByDefault(t *testing.T) {
+	h := newTestMux(t)
+	data := map[string]any{}
+	mustCall(t, h, inimcloud.MethodActivateScenario, activate(2), &data)
+	if _, ok := data["CommandId"]; ok {
+		t.Errorf("ActivateScenario = %v, want no command without -async-activation", data)
+	}
+	if got := getDevice(t, h).ActiveScenario; got != 2 {
+		t.Errorf("scenario = %d, want 2", got)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Degraded mode models a cloud that throttles under load: once more than
+// degradeAfter requests arrive within one window, every further request is
+// delayed a little more, and past degradeFailAfter extra requests they are
+// rejected outright. The counter resets when the window ends, which is how
+// the service recovers.
+var (
+	degradeAfter     = flag.Int("degrade-after", 0, "start degrading after this many requests per window (0 disables)")
+	degradeWindow    = flag.Duration("degrade-window", time.Minute, "window over which requests are counted; degradation clears when it ends")
+	degradeStep      = flag.Duration("degrade-step", 100*time.Millisecond, "extra latency added per request over the threshold")
+	degradeFailAfter = flag.Int("degrade-fail-after", 10, "requests over the threshold before answering 503")
+)
+
+type degrader struct {
+	mu          sync.Mutex
+	windowStart time.Time
+	count       int
+}
+
+// degradation counts the requests of every transport, which share the load.
+var degradation = &degrader{}
+
+// hit records a request and returns the latency to add and whether the
+// request should be rejected.
+func (d *degrader) hit(now time.Time) (time.Duration, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if now.Sub(d.windowStart) >= *degradeWindow {
+		d.windowStart = now
+		d.count = 0
+	}
+	d.count++
+
+	over := d.count - *degradeAfter
+	if over <= 0 {
+		return 0, false
+	}
+	if over > *degradeFailAfter {
+		return 0, true
+	}
+	return time.Duration(over) * *degradeStep, false
+}
+
+// Degrade wraps next with the degraded mode, which is a no-op unless
+// -degrade-after is set.
+func Degrade(next http.HandlerFunc) http.HandlerFunc {
+	if *degradeAfter <= 0 {
+		return next
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		delay, fail := degradation.hit(clock.Now())
+		if fail {
+			WriteError(w, http.StatusServiceUnavailable, "Service degraded, try again later")
+			return
+		}
+		clock.Sleep(delay)
+		next(w, r)
+	}
+}
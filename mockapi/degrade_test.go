@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/lacherogwu/ha-inim_cloud/mockapi/inimcloud"
+)
+
+func TestDegradeSlowsThenFailsThenRecovers(t *testing.T) {
+	fake := useFakeClock(t)
+	setFlag(t, degradeAfter, 2)
+	setFlag(t, degradeWindow, time.Minute)
+	setFlag(t, degradeStep, 100*time.Millisecond)
+	setFlag(t, degradeFailAfter, 2)
+	h := newTestMux(t)
+
+	call := func() int {
+		rec, _ := callApi(t, h, inimcloud.Request{Method: inimcloud.MethodGetSystemTime})
+		return rec.Code
+	}
+	for i := range 2 {
+		if code := call(); code != http.StatusOK || fake.Slept() != 0 {
+			t.Fatalf("request %d under the threshold: %d, delayed", i+1, code)
+		}
+	}
+	for _, want := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond} {
+		if code := call(); code != http.StatusOK {
+			t.Fatalf("degraded request = %d", code)
+		}
+		if slept := fake.Slept(); slept != want {
+			t.Errorf("degraded request delayed %v, want %v", slept, want)
+		}
+	}
+	if code := call(); code != http.StatusServiceUnavailable {
+		t.Errorf("request past -degrade-fail-after = %d, want 503", code)
+	}
+
+	fake.Advance(time.Minute)
+	if code := call(); code != http.StatusOK || fake.Slept() != 0 {
+		t.Errorf("request in a new window = %d, delayed", code)
+	}
+}
+
+func TestDegradeSharedAcrossTransports(t *testing.T) {
+	useFakeClock(t)
+	setFlag(t, degradeAfter, 1)
+	setFlag(t, degradeFailAfter, 0)
+	h := newTestMux(t)
+
+	callApi(t, h, inimcloud.Request{Method: inimcloud.MethodGetSystemTime})
+	rec := post(t, h, "/rpc", `{"jsonrpc":"2.0","method":"GetSystemTime","id":1}`)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("/rpc after the threshold was reached on / = %d, want 503", rec.Code)
+	}
+}
+
+func TestDegradeDisabledByDefault(t *testing.T) {
+	fake := useFakeClock(t)
+	h := newTestMux(t)
+	for range 50 {
+		if rec, _ := callApi(t, h, inimcloud.Request{Method: inimcloud.MethodGetSystemTime}); rec.Code != http.StatusOK {
+			t.Fatalf("GetSystemTime = %d", rec.Code)
+		}
+	}
+	if slept := fake.Slept(); slept != 0 {
+		t.Errorf("requests delayed %v without -degrade-after", slept)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/lacherogwu/ha-inim_cloud/mockapi/inimcloud"
+)
+
+// Panels count down an exit delay after arming and an entry delay after a
+// door opens while armed. Both are set per device, in seconds; the exit
+// delay is counted down when -exit-delay is on.
+
+type Delays struct {
+	EntryDelay int `json:"EntryDelay"`
+	ExitDelay  int `json:"ExitDelay"`
+}
+
+// defaultDelays applies to devices whose delays were never set.
+var defaultDelays = Delays{EntryDelay: 30, ExitDelay: 30}
+
+// maxDelay is the longest delay a panel accepts, in seconds.
+const maxDelay = 255
+
+// DeviceDelays returns the delays configured for a device. The caller must
+// hold stateMu.
+func DeviceDelays(deviceId int) Delays {
+	if d, ok := store.Delays(deviceId); ok {
+		return d
+	}
+	return defaultDelays
+}
+
+func HandleGetDelays(w http.ResponseWriter, reqData *inimcloud.Request) {
+	v := validateParams(reqData.Params)
+	deviceId := v.Int("DeviceId")
+	if !v.Check(w) {
+		return
+	}
+	stateMu.Lock()
+	defer stateMu.Unlock()
+
+	if _, found := store.Device(deviceId); !found {
+		WriteStatus(w, ErrUnknownDevice, "Device not found")
+		return
+	}
+	WriteJson(w, DeviceDelays(deviceId))
+}
+
+// HandleSetDelays updates the delays given and keeps the other one.
+func HandleSetDelays(w http.ResponseWriter, reqData *inimcloud.Request) {
+	v := validateParams(reqData.Params)
+	deviceId := v.Int("DeviceId")
+	entryDelay, setEntry := v.OptionalInt("EntryDelay")
+	exitDelay, setExit := v.OptionalInt("ExitDelay")
+	if setEntry && (entryDelay < 0 || entryDelay > maxDelay) {
+		v.Reject("EntryDelay", "must be between 0 and 255 seconds")
+	}
+	if setExit && (exitDelay < 0 || exitDelay > maxDelay) {
+		v.Reject("ExitDelay", "must be between 0 and 255 seconds")
+	}
+	if !v.Check(w) {
+		return
+	}
+	stateMu.Lock()
+	defer stateMu.Unlock()
+
+	if _, found := store.Device(deviceId); !found {
+		WriteStatus(w, ErrUnknownDevice, "Device not found")
+		return
+	}
+	d := DeviceDelays(deviceId)
+	if setEntry {
+		d.EntryDelay = entryDelay
+	}
+	if setExit {
+		d.ExitDelay = exitDelay
+	}
+	store.SetDelays(deviceId, d)
+	WriteJson(w, d)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/lacherogwu/ha-inim_cloud/mockapi/inimcloud"
+)
+
+func getDelays(t *testing.T, h http.Handler) Delays {
+	t.Helper()
+	d := Delays{}
+	mustCall(t, h, inimcloud.MethodGetDelays, map[string]any{"DeviceId": testDevice}, &d)
+	return d
+}
+
+func TestSetDelaysKeepsTheOther(t *testing.T) {
+	h := newTestMux(t)
+	if d := getDelays(t, h); d != defaultDelays {
+		t.Errorf("initial delays = %+v, want %+v", d, defaultDelays)
+	}
+
+	mustCall(t, h, inimcloud.MethodSetDelays, map[string]any{"DeviceId": testDevice, "ExitDelay": 10}, nil)
+	if d, want := getDelays(t, h), (Delays{EntryDelay: 30, ExitDelay: 10}); d != want {
+		t.Errorf("after setting ExitDelay: %+v, want %+v", d, want)
+	}
+}
+
+func TestSetDelaysRange(t *testing.T) {
+	h := newTestMux(t)
+	got := validationFields(t, h, inimcloud.MethodSetDelays, map[string]any{
+		"DeviceId":   testDevice,
+		"EntryDelay": -1,
+		"ExitDelay":  256,
+	})
+	if len(got) != 2 {
+		t.Errorf("fields = %+v, want both delays rejected", got)
+	}
+	if d := getDelays(t, h); d != defaultDelays {
+		t.Errorf("rejected request changed delays to %+v", d)
+	}
+}
+
+func TestExitDelayDrivesArming(t *testing.T) {
+	setFlag(t, exitDelayArming, true)
+	c := useFakeClock(t)
+	h := newTestMux(t)
+	mustCall(t, h, inimcloud.MethodSetDelays, map[string]any{"DeviceId": testDevice, "ExitDelay": 10}, nil)
+
+	mustCall(t, h, inimcloud.MethodActivateScenario, activate(2), nil)
+	c.Advance(9 * time.Second)
+	device := getDevice(t, h)
+	if device.ActiveScenario != 1 || device.ExitDelay == nil || device.ExitDelay.ScenarioId != 2 {
+		t.Fatalf("before the delay ran out: scenario %d, exit delay %+v", device.ActiveScenario, device.ExitDelay)
+	}
+
+	c.Advance(time.Second)
+	device = getDevice(t, h)
+	if device.ActiveScenario != 2 || device.ExitDelay != nil {
+		t.Errorf("after the delay: scenario %d, exit delay %+v, want 2 and none", device.ActiveScenario, device.ExitDelay)
+	}
+}
+
+func TestZeroExitDelayArmsAtOnce(t *testing.T) {
+	setFlag(t, exitDelayArming, true)
+	useFakeClock(t)
+	h := newTestMux(t)
+	mustCall(t, h, inimcloud.MethodSetDelays, map[string]any{"DeviceId": testDevice, "ExitDelay": 0}, nil)
+
+	mustCall(t, h, inimcloud.MethodActivateScenario, activate(2), nil)
+	if device := getDevice(t, h); device.ActiveScenario != 2 || device.ExitDelay != nil {
+		t.Errorf("scenario %d, exit delay %+v, want 2 at once", device.ActiveScenario, device.ExitDelay)
+	}
+}
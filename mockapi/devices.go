@@ -0,0 +1,127 @@
+package main
+
+import (
+	"flag"
+	"sync/atomic"
+
+	"github.com/lacherogwu/ha-inim_cloud/mockapi/inimcloud"
+)
+
+// Panels only process so many commands: with -command-cooldown, an
+// activation arriving sooner than that after the previous one accepted for
+// the same device is refused.
+var commandCooldown = flag.Duration("command-cooldown", 0, "minimum time between activations of one device")
+
+// deviceActions are the methods a device's Capabilities can withhold. Reads
+// of the catalog and global methods such as GetCommandStatus answer for any
+// device, whatever DeviceId they are given.
+var deviceActions = map[inimcloud.Method]bool{
+	inimcloud.MethodActivateScenario:        true,
+	inimcloud.MethodGetFaults:               true,
+	inimcloud.MethodAckFault:                true,
+	inimcloud.MethodGetScenarioHistory:      true,
+	inimcloud.MethodGetNotificationSettings: true,
+	inimcloud.MethodSetNotificationSettings: true,
+	inimcloud.MethodGetDelays:               true,
+	inimcloud.MethodSetDelays:               true,
+	inimcloud.MethodGetEventsLatest:         true,
+	inimcloud.MethodCancelActivation:        true,
+}
+
+// defaultDevices is the device catalog the mock starts with.
+var defaultDevices = []Device{
+	{
+		Device: inimcloud.Device{
+			DeviceId: 545002,
+			Name:     "BLUEBERR 3",
+			Scenarios: []inimcloud.Scenario{
+				{ScenarioId: 0, Name: "ARM"},
+				{ScenarioId: 1, Name: "DISARM"},
+				{ScenarioId: 2, Name: "STAY"},
+			},
+			Areas: []inimcloud.Area{
+				{AreaId: 1, Name: "House"},
+			},
+			Zones: []inimcloud.Zone{
+				{ZoneId: 1, Type: 1, Name: "Front door", Areas: []int{1}, Visibility: true},
+			},
+			Capabilities: []inimcloud.Method{
+				inimcloud.MethodActivateScenario,
+				inimcloud.MethodGetFaults,
+				inimcloud.MethodAckFault,
+				inimcloud.MethodGetScenarioHistory,
+				inimcloud.MethodGetNotificationSettings,
+				inimcloud.MethodSetNotificationSettings,
+				inimcloud.MethodGetDelays,
+				inimcloud.MethodSetDelays,
+				inimcloud.MethodGetEventsLatest,
+				inimcloud.MethodCancelActivation,
+			},
+		},
+		FirmwareVersion: "6.07",
+		DisarmScenarios: []int{1},
+		Notifications: map[NotificationEvent]bool{
+			NotifyArmed:    false,
+			NotifyDisarmed: false,
+		},
+	},
+}
+
+// DeviceViews returns every device with its live state as reads see it. The
+// caller must hold stateMu.
+func DeviceViews() []inimcloud.DeviceView {
+	devices := store.Devices()
+	now := clock.Now()
+	views := make([]inimcloud.DeviceView, 0, len(devices))
+	for _, device := range devices {
+		scenarioId := VisibleScenario(device.DeviceId)
+		views = append(views, inimcloud.DeviceView{
+			Device:             device.Device,
+			ActiveScenario:     scenarioId,
+			ActiveScenarioName: device.ScenarioName(scenarioId),
+			Version:            store.Version(device.DeviceId),
+			Firmware:           Firmware(device, now),
+			Areas:              AreaViews(device, scenarioId),
+			Zones:              ZoneViews(device),
+			Alarm:              InAlarm(device),
+			ExitDelay:          PendingExitDelay(device.DeviceId),
+		})
+	}
+	return views
+}
+
+// ValidateActivation checks that scenarioId may be activated on deviceId
+// right now. The caller must hold stateMu.
+func ValidateActivation(deviceId, scenarioId int) *ApiError {
+	device, ok := store.Device(deviceId)
+	if !ok {
+		return &ApiError{ErrUnknownDevice, "Device not found"}
+	}
+	if _, ok := device.Scenario(scenarioId); !ok {
+		return &ApiError{ErrUnknownScenario, "Scenario not found"}
+	}
+	if !TransitionAllowed(deviceId, store.ActiveScenario(deviceId), scenarioId) {
+		return &ApiError{ErrTransitionNotAllowed, "Scenario transition not allowed"}
+	}
+	if clock.Now().Sub(store.LastCommandAt(deviceId)) < *commandCooldown {
+		return &ApiError{ErrCommandTooSoon, "Command too soon, panel is busy"}
+	}
+	return nil
+}
+
+// SetActiveScenario changes a device's active scenario on behalf of actor.
+// The caller must hold stateMu.
+func SetActiveScenario(deviceId, scenarioId int, actor string) {
+	RecordPropagation(deviceId, scenarioId)
+	RecordHistory(deviceId, scenarioId, actor)
+	store.SetActiveScenario(deviceId, scenarioId)
+	RecordEvent(Event{DeviceId: deviceId, Type: EventScenarioChanged, ScenarioId: &scenarioId})
+}
+
+// sequence numbers every accepted activation, across all devices, so clients
+// can spot dropped or reordered responses and resync.
+var sequence atomic.Int64
+
+func NextSequence() int64 {
+	return sequence.Add(1)
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lacherogwu/ha-inim_cloud/mockapi/inimcloud"
+)
+
+func TestActivationSequenceIncreases(t *testing.T) {
+	h := newTestMux(t)
+	last := int64(0)
+	for _, scenarioId := range []int{2, 0, 1} {
+		data := struct{ Sequence int64 }{}
+		mustCall(t, h, inimcloud.MethodActivateScenario, activate(scenarioId), &data)
+		if data.Sequence <= last {
+			t.Errorf("Sequence = %d after %d", data.Sequence, last)
+		}
+		last = data.Sequence
+	}
+}
+
+func TestEventIdsHaveNoGaps(t *testing.T) {
+	h := newTestMux(t)
+	for _, scenarioId := range []int{2, 0, 1, 2} {
+		mustCall(t, h, inimcloud.MethodActivateScenario, activate(scenarioId), nil)
+		mustCall(t, h, inimcloud.MethodGetDevicesExtended, nil, nil)
+	}
+	events := struct{ Events []Event }{}
+	mustCall(t, h, inimcloud.MethodGetEventsLatest, nil, &events)
+
+	if len(events.Events) != 4 {
+		t.Fatalf("events = %+v, want one per activation", events.Events)
+	}
+	for i, event := range events.Events {
+		if event.EventId != i+1 {
+			t.Errorf("event %d has EventId %d, want %d", i, event.EventId, i+1)
+		}
+	}
+}
+
+func TestCapabilitiesGateDeviceActions(t *testing.T) {
+	resetState()
+	limited := defaultDevices[0]
+	limited.Capabilities = []inimcloud.Method{inimcloud.MethodActivateScenario}
+	store = NewMemoryStore([]Device{limited}, map[int]int{testDevice: 1})
+	h := NewMux()
+
+	mustCall(t, h, inimcloud.MethodActivateScenario, activate(2), nil)
+	if status := callStatus(t, h, inimcloud.MethodGetDelays, map[string]any{"DeviceId": testDevice}); status != int(ErrNotSupported) {
+		t.Errorf("GetDelays without the capability = %d, want %d", status, ErrNotSupported)
+	}
+}
+
+func TestCapabilitiesDontGateReads(t *testing.T) {
+	resetState()
+	limited := defaultDevices[0]
+	limited.Capabilities = []inimcloud.Method{}
+	store = NewMemoryStore([]Device{limited}, map[int]int{testDevice: 1})
+	h := NewMux()
+
+	mustCall(t, h, inimcloud.MethodGetDevicesExtended, map[string]any{"DeviceId": "545002"}, nil)
+	mustCall(t, h, inimcloud.MethodGetSystemTime, map[string]any{"DeviceId": testDevice}, nil)
+	rec, reply := callApi(t, h, inimcloud.Request{Method: inimcloud.MethodGetCommandStatus, Params: map[string]any{"DeviceId": testDevice, "CommandId": "1"}})
+	if reply.Status == int(ErrNotSupported) {
+		t.Errorf("GetCommandStatus gated by capabilities: %s", rec.Body)
+	}
+}
+
+func TestDeviceWithoutCapabilitiesSupportsEverything(t *testing.T) {
+	resetState()
+	open := defaultDevices[0]
+	open.Capabilities = nil
+	store = NewMemoryStore([]Device{open}, map[int]int{testDevice: 1})
+	h := NewMux()
+
+	for method := range deviceActions {
+		if status := callStatus(t, h, method, map[string]any{"DeviceId": testDevice}); status == int(ErrNotSupported) {
+			t.Errorf("%s refused on a device declaring no capabilities", method)
+		}
+	}
+}
+
+func TestConcurrentActivationsAreSerialized(t *testing.T) {
+	h := newTestMux(t)
+	const n = 50
+	type result struct {
+		Sequence int64
+		Version  int
+	}
+	results := make([]result, n)
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, reply := callApi(t, h, inimcloud.Request{Method: inimcloud.MethodActivateScenario, Params: activate(i % 3)})
+			json.Unmarshal(reply.Data, &results[i])
+		}()
+	}
+	wg.Wait()
+
+	// Each activation got its own version, and versions follow sequence
+	// numbers: they were applied one at a time, in order.
+	slices.SortFunc(results, func(a, b result) int { return a.Version - b.Version })
+	for i, res := range results {
+		if res.Version != i+1 {
+			t.Fatalf("versions = %+v, want 1 to %d", results, n)
+		}
+		if i > 0 && res.Sequence <= results[i-1].Sequence {
+			t.Errorf("version %d has sequence %d, after %d", res.Version, res.Sequence, results[i-1].Sequence)
+		}
+	}
+	history := struct{ History []HistoryEntry }{}
+	mustCall(t, h, inimcloud.MethodGetScenarioHistory, map[string]any{"DeviceId": testDevice}, &history)
+	if len(history.History) != min(n, *historySize) {
+		t.Errorf("history has %d entries, want %d", len(history.History), min(n, *historySize))
+	}
+}
+
+func TestCommandCooldownPerDevice(t *testing.T) {
+	setFlag(t, commandCooldown, time.Second)
+	c := useFakeClock(t)
+	h := newTestMux(t)
+	store = NewMemoryStore(manyDevices(2))
+	first := map[string]any{"DeviceId": 1, "ScenarioId": 2}
+	other := map[string]any{"DeviceId": 2, "ScenarioId": 2}
+
+	mustCall(t, h, inimcloud.MethodActivateScenario, first, nil)
+	c.Advance(500 * time.Millisecond)
+	first["ScenarioId"] = 1
+	if status := callStatus(t, h, inimcloud.MethodActivateScenario, first); status != int(ErrCommandTooSoon) {
+		t.Errorf("second activation within the cooldown: Status = %d, want %d", status, ErrCommandTooSoon)
+	}
+	mustCall(t, h, inimcloud.MethodActivateScenario, other, nil)
+
+	c.Advance(500 * time.Millisecond)
+	mustCall(t, h, inimcloud.MethodActivateScenario, first, nil)
+}
+
+func TestRejectedActivationDoesNotRestartCooldown(t *testing.T) {
+	setFlag(t, commandCooldown, time.Second)
+	c := useFakeClock(t)
+	h := newTestMux(t)
+
+	mustCall(t, h, inimcloud.MethodActivateScenario, activate(2), nil)
+	c.Advance(900 * time.Millisecond)
+	callStatus(t, h, inimcloud.MethodActivateScenario, activate(1))
+	c.Advance(100 * time.Millisecond)
+	mustCall(t, h, inimcloud.MethodActivateScenario, activate(1), nil)
+}
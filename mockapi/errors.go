@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// ErrorCode is the non-zero Status reported in the envelope when a request
+// is well formed but can't be carried out, alongside an ErrMsg. The values
+// below are the defaults; -status-map can report others on the wire.
+type ErrorCode int
+
+const (
+	ErrUnknownDevice        ErrorCode = 4
+	ErrUnknownScenario      ErrorCode = 5
+	ErrTransitionNotAllowed ErrorCode = 10
+	ErrVersionConflict      ErrorCode = 11
+	ErrMaintenance          ErrorCode = 12
+	ErrNotSupported         ErrorCode = 13
+	ErrUnknownFault         ErrorCode = 14
+	ErrRateLimited          ErrorCode = 15
+	ErrCommandTooSoon       ErrorCode = 16
+	ErrDeviceUpdating       ErrorCode = 17
+	ErrInvalidCredentials   ErrorCode = 18
+	ErrInvalidToken         ErrorCode = 19
+	ErrNothingPending       ErrorCode = 20
+	ErrSessionActive        ErrorCode = 21
+)
+
+// errorNames lets configuration files refer to error codes by name.
+var errorNames = map[string]ErrorCode{
+	"ErrUnknownDevice":        ErrUnknownDevice,
+	"ErrUnknownScenario":      ErrUnknownScenario,
+	"ErrTransitionNotAllowed": ErrTransitionNotAllowed,
+	"ErrVersionConflict":      ErrVersionConflict,
+	"ErrMaintenance":          ErrMaintenance,
+	"ErrNotSupported":         ErrNotSupported,
+	"ErrUnknownFault":         ErrUnknownFault,
+	"ErrRateLimited":          ErrRateLimited,
+	"ErrCommandTooSoon":       ErrCommandTooSoon,
+	"ErrDeviceUpdating":       ErrDeviceUpdating,
+	"ErrInvalidCredentials":   ErrInvalidCredentials,
+	"ErrInvalidToken":         ErrInvalidToken,
+	"ErrNothingPending":       ErrNothingPending,
+	"ErrSessionActive":        ErrSessionActive,
+}
+
+// ApiError is a failed call as reported to the client.
+type ApiError struct {
+	Code    ErrorCode
+	Message string
+}
+
+func (e *ApiError) Error() string {
+	return e.Message
+}
+
+func WriteApiError(w http.ResponseWriter, err *ApiError) {
+	WriteStatus(w, err.Code, err.Message)
+}
+
+func WriteStatus(w http.ResponseWriter, code ErrorCode, message string) {
+	WriteStatusCode(w, http.StatusOK, code, message)
+}
+
+// WriteStatusCode is WriteStatus for the few errors the cloud also reports
+// through the HTTP status.
+func WriteStatusCode(w http.ResponseWriter, httpStatus int, code ErrorCode, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(httpStatus)
+
+	resData := map[string]any{
+		*statusField: WireStatus(code),
+		"ErrMsg":     message,
+	}
+
+	if err := json.NewEncoder(w).Encode(resData); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+	"time"
+
+	"github.com/lacherogwu/ha-inim_cloud/mockapi/inimcloud"
+)
+
+// State changes are also recorded as events, so clients can follow them
+// with GetEventsLatest instead of re-reading every device. Events are
+// numbered from a sequence of their own, without gaps: an EventId that skips
+// means events were missed, and the client should resync. Passing WaitMs
+// turns the call into a long poll that returns as soon as an event after
+// AfterId is recorded.
+//
+// A client back from downtime backfills with Since, an EventId or a time,
+// and Limit: events come oldest first, and HasMore tells it to call again
+// with the EventId of the last one as Since. Only the last -event-log-size
+// events are kept, so the log should be sized for the outages to bridge.
+var eventLogSize = flag.Int("event-log-size", 500, "number of events kept for GetEventsLatest")
+
+// maxEventWait caps how long GetEventsLatest waits for an event.
+const maxEventWait = 30 * time.Second
+
+type EventType string
+
+const (
+	EventScenarioChanged       EventType = "ScenarioChanged"
+	EventExitDelayStarted      EventType = "ExitDelayStarted"
+	EventExitDelayCancelled    EventType = "ExitDelayCancelled"
+	EventZoneOpened            EventType = "ZoneOpened"
+	EventZoneClosed            EventType = "ZoneClosed"
+	EventAlarmRaised           EventType = "AlarmRaised"
+	EventAlarmCleared          EventType = "AlarmCleared"
+	EventFaultRaised           EventType = "FaultRaised"
+	EventFirmwareUpdateStarted EventType = "FirmwareUpdateStarted"
+	EventFirmwareUpdated       EventType = "FirmwareUpdated"
+)
+
+type Event struct {
+	EventId    int       `json:"EventId"`
+	DeviceId   int       `json:"DeviceId"`
+	Type       EventType `json:"Type"`
+	At         time.Time `json:"At"`
+	ScenarioId *int      `json:"ScenarioId,omitempty"`
+	AreaId     *int      `json:"AreaId,omitempty"`
+	ZoneId     *int      `json:"ZoneId,omitempty"`
+	FaultId    *int      `json:"FaultId,omitempty"`
+	// FirmwareVersion is the version a firmware update installs.
+	FirmwareVersion *string `json:"FirmwareVersion,omitempty"`
+}
+
+// eventsNotify is closed, and replaced, whenever an event is recorded,
+// waking up long polls. It is guarded by stateMu.
+var eventsNotify = make(chan struct{})
+
+// eventSequence is the EventId of the last event recorded. It is guarded by
+// stateMu.
+var eventSequence int
+
+// RecordEvent appends an event to the log. The caller must hold stateMu.
+func RecordEvent(event Event) Event {
+	eventSequence++
+	event.EventId = eventSequence
+	event.At = clock.Now()
+
+	store.AppendEvent(event, *eventLogSize)
+	close(eventsNotify)
+	eventsNotify = make(chan struct{})
+	return event
+}
+
+// eventsAfter returns up to limit events after afterId and at or after
+// since, oldest first, and whether more follow them. With neither set, it
+// returns the latest limit events. The caller must hold stateMu.
+func eventsAfter(afterId int, since time.Time, deviceId, limit int) ([]Event, bool) {
+	list := []Event{}
+	for _, event := range store.Events() {
+		if event.EventId > afterId && !event.At.Before(since) && (deviceId == 0 || event.DeviceId == deviceId) {
+			list = append(list, event)
+		}
+	}
+	if afterId == 0 && since.IsZero() && len(list) > limit {
+		return list[len(list)-limit:], false
+	}
+	if len(list) > limit {
+		return list[:limit], true
+	}
+	return list, false
+}
+
+// sinceParam reads Since, which is either an EventId, like AfterId, or an
+// RFC 3339 time.
+func sinceParam(v *paramValidator) (int, time.Time) {
+	value, present := v.params["Since"]
+	if !present {
+		return 0, time.Time{}
+	}
+	if id, ok := paramInt(v.params, "Since"); ok && id >= 0 {
+		return id, time.Time{}
+	}
+	if text, ok := value.(string); ok {
+		if at, err := time.Parse(time.RFC3339, text); err == nil {
+			return 0, at
+		}
+	}
+	v.Reject("Since", "must be an EventId or an RFC 3339 time")
+	return 0, time.Time{}
+}
+
+func HandleGetEventsLatest(w http.ResponseWriter, reqData *inimcloud.Request) {
+	v := validateParams(reqData.Params)
+	afterId, _ := v.OptionalInt("AfterId")
+	sinceId, since := sinceParam(v)
+	afterId = max(afterId, sinceId)
+	deviceId, _ := v.OptionalInt("DeviceId")
+	limit, ok := v.OptionalInt("Limit")
+	if !ok {
+		limit = 50
+	}
+	waitMs, _ := v.OptionalInt("WaitMs")
+	if limit < 1 {
+		v.Reject("Limit", "must be at least 1")
+	}
+	if waitMs < 0 {
+		v.Reject("WaitMs", "must not be negative")
+	}
+	if !v.Check(w) {
+		return
+	}
+
+	timeout := make(chan struct{})
+	timer := clock.AfterFunc(min(time.Duration(waitMs)*time.Millisecond, maxEventWait), func() { close(timeout) })
+	defer timer.Stop()
+	stateMu.Lock()
+	if deviceId != 0 {
+		if _, found := store.Device(deviceId); !found {
+			stateMu.Unlock()
+			WriteStatus(w, ErrUnknownDevice, "Device not found")
+			return
+		}
+	}
+	list, hasMore := eventsAfter(afterId, since, deviceId, limit)
+	for len(list) == 0 && waitMs > 0 {
+		notify := eventsNotify
+		stateMu.Unlock()
+		select {
+		case <-notify:
+		case <-timeout:
+			waitMs = 0
+		}
+		stateMu.Lock()
+		list, hasMore = eventsAfter(afterId, since, deviceId, limit)
+	}
+	lastEventId := 0
+	if log := store.Events(); len(log) > 0 {
+		lastEventId = log[len(log)-1].EventId
+	}
+	stateMu.Unlock()
+
+	WriteJson(w, map[string]any{
+		"Events":      list,
+		"HasMore":     hasMore,
+		"LastEventId": lastEventId,
+	})
+}
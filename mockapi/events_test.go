@@ -0,0 +1,214 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/lacherogwu/ha-inim_cloud/mockapi/inimcloud"
+)
+
+type eventsReply struct {
+	Events      []Event
+	HasMore     bool
+	LastEventId int
+}
+
+func getEvents(t *testing.T, h http.Handler, params map[string]any) eventsReply {
+	t.Helper()
+	data := eventsReply{}
+	mustCall(t, h, inimcloud.MethodGetEventsLatest, params, &data)
+	return data
+}
+
+func eventTypes(events []Event) []EventType {
+	types := []EventType{}
+	for _, event := range events {
+		types = append(types, event.Type)
+	}
+	return types
+}
+
+func TestExitDelayEmitsEvents(t *testing.T) {
+	setFlag(t, exitDelayArming, true)
+	c := useFakeClock(t)
+	h := newTestMux(t)
+
+	mustCall(t, h, inimcloud.MethodActivateScenario, activate(0), nil)
+	started := getEvents(t, h, nil)
+	if len(started.Events) != 1 || started.Events[0].Type != EventExitDelayStarted {
+		t.Fatalf("events after arming = %v, want ExitDelayStarted", eventTypes(started.Events))
+	}
+	if device := getDevice(t, h); device.ExitDelay == nil || !device.ExitDelay.EndsAt.Equal(c.Now().Add(30*time.Second)) {
+		t.Errorf("pending exit delay = %+v, want one ending in 30s", device.ExitDelay)
+	}
+
+	c.Advance(30 * time.Second)
+	done := getEvents(t, h, map[string]any{"AfterId": started.LastEventId})
+	if len(done.Events) != 1 || done.Events[0].Type != EventScenarioChanged || *done.Events[0].ScenarioId != 0 {
+		t.Fatalf("events after the delay = %+v, want ScenarioChanged to 0", done.Events)
+	}
+	if id := done.Events[0].EventId; id != started.LastEventId+1 {
+		t.Errorf("EventId %d, want %d", id, started.LastEventId+1)
+	}
+	if done.LastEventId != done.Events[0].EventId {
+		t.Errorf("LastEventId = %d, want %d", done.LastEventId, done.Events[0].EventId)
+	}
+}
+
+func TestDisarmingCancelsExitDelay(t *testing.T) {
+	setFlag(t, exitDelayArming, true)
+	c := useFakeClock(t)
+	h := newTestMux(t)
+
+	mustCall(t, h, inimcloud.MethodActivateScenario, activate(0), nil)
+	c.Advance(10 * time.Second)
+	mustCall(t, h, inimcloud.MethodActivateScenario, activate(1), nil)
+	c.Advance(time.Minute)
+
+	if device := getDevice(t, h); device.ActiveScenario != 1 || device.ExitDelay != nil {
+		t.Errorf("scenario %d, exit delay %+v, want disarmed with no countdown", device.ActiveScenario, device.ExitDelay)
+	}
+	want := []EventType{EventExitDelayStarted, EventScenarioChanged}
+	if got := eventTypes(getEvents(t, h, nil).Events); len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("events = %v, want %v", got, want)
+	}
+}
+
+func TestCancelActivation(t *testing.T) {
+	setFlag(t, exitDelayArming, true)
+	c := useFakeClock(t)
+	h := newTestMux(t)
+
+	mustCall(t, h, inimcloud.MethodActivateScenario, activate(0), nil)
+	c.Advance(10 * time.Second)
+	data := struct{ ActiveScenario, Version int }{}
+	mustCall(t, h, inimcloud.MethodCancelActivation, map[string]any{"DeviceId": testDevice}, &data)
+	if data.ActiveScenario != 1 {
+		t.Errorf("cancel = %+v, want the device back on scenario 1", data)
+	}
+	c.Advance(time.Minute)
+
+	device := getDevice(t, h)
+	if device.ActiveScenario != 1 || device.ExitDelay != nil || device.Version != data.Version {
+		t.Errorf("after the cancelled countdown: scenario %d, exit delay %+v, version %d", device.ActiveScenario, device.ExitDelay, device.Version)
+	}
+	events := getEvents(t, h, nil).Events
+	if got := eventTypes(events); !slices.Equal(got, []EventType{EventExitDelayStarted, EventExitDelayCancelled}) {
+		t.Errorf("events = %v, want the countdown started and cancelled", got)
+	}
+	if status := callStatus(t, h, inimcloud.MethodCancelActivation, map[string]any{"DeviceId": testDevice}); status != int(ErrNothingPending) {
+		t.Errorf("cancel with nothing pending = %d, want %d", status, ErrNothingPending)
+	}
+}
+
+func TestLongPollWakesOnEvent(t *testing.T) {
+	useFakeClock(t)
+	h := newTestMux(t)
+	last := getEvents(t, h, nil).LastEventId
+
+	reply := make(chan eventsReply)
+	go func() {
+		reply <- getEvents(t, h, map[string]any{"AfterId": last, "WaitMs": 30000})
+	}()
+	post(t, h, "/admin/devices/545002/zones/1/open", "")
+
+	select {
+	case data := <-reply:
+		if len(data.Events) != 1 || data.Events[0].Type != EventZoneOpened {
+			t.Errorf("long poll = %v, want ZoneOpened", eventTypes(data.Events))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("long poll did not wake up")
+	}
+}
+
+func TestLongPollTimesOut(t *testing.T) {
+	c := useFakeClock(t)
+	h := newTestMux(t)
+
+	reply := make(chan eventsReply)
+	go func() {
+		reply <- getEvents(t, h, map[string]any{"WaitMs": 1000})
+	}()
+	// The timer is only set once the call is under way.
+	for {
+		select {
+		case data := <-reply:
+			if len(data.Events) != 0 {
+				t.Errorf("timed out long poll = %+v, want no events", data.Events)
+			}
+			return
+		case <-time.After(time.Millisecond):
+			c.Advance(time.Second)
+		}
+	}
+}
+
+func TestEventLogSize(t *testing.T) {
+	setFlag(t, eventLogSize, 3)
+	h := newTestMux(t)
+	for range 3 {
+		post(t, h, "/admin/devices/545002/zones/1/open", "")
+		post(t, h, "/admin/devices/545002/zones/1/close", "")
+	}
+	data := getEvents(t, h, nil)
+	if len(data.Events) != 3 || data.Events[2].EventId != data.LastEventId {
+		t.Errorf("log = %+v, want the last 3 events", data.Events)
+	}
+	if older := getEvents(t, h, map[string]any{"Limit": 2}); len(older.Events) != 2 || older.Events[1].EventId != data.LastEventId {
+		t.Errorf("Limit 2 = %+v, want the latest 2", older.Events)
+	}
+}
+
+func TestBackfillAfterAnOutage(t *testing.T) {
+	c := useFakeClock(t)
+	h := newTestMux(t)
+	post(t, h, "/admin/devices/545002/zones/1/open", "")
+	seen := getEvents(t, h, nil).LastEventId
+
+	// The client is away while seven more events happen.
+	c.Advance(time.Minute)
+	back := c.Now()
+	for range 3 {
+		post(t, h, "/admin/devices/545002/zones/1/close", "")
+		post(t, h, "/admin/devices/545002/zones/1/open", "")
+	}
+	mustCall(t, h, inimcloud.MethodActivateScenario, activate(2), nil)
+
+	backfilled := []int{}
+	for since, pages := seen, 0; ; pages++ {
+		if pages == 4 {
+			t.Fatal("backfill did not catch up in 4 pages")
+		}
+		page := getEvents(t, h, map[string]any{"Since": since, "Limit": 3})
+		for _, event := range page.Events {
+			backfilled = append(backfilled, event.EventId)
+			since = event.EventId
+		}
+		if !page.HasMore {
+			break
+		}
+	}
+	want := []int{seen + 1, seen + 2, seen + 3, seen + 4, seen + 5, seen + 6, seen + 7}
+	if !slices.Equal(backfilled, want) {
+		t.Errorf("backfilled %v, want %v", backfilled, want)
+	}
+
+	byTime := getEvents(t, h, map[string]any{"Since": back.Format(time.RFC3339), "Limit": 10})
+	if len(byTime.Events) != 7 || byTime.Events[0].EventId != seen+1 || byTime.HasMore {
+		t.Errorf("Since %s = %+v, want the 7 events of the outage", back.Format(time.RFC3339), byTime)
+	}
+}
+
+func TestGetEventsLatestValidation(t *testing.T) {
+	h := newTestMux(t)
+	got := validationFields(t, h, inimcloud.MethodGetEventsLatest, map[string]any{"Limit": 0, "WaitMs": -1, "Since": "yesterday"})
+	if len(got) != 3 {
+		t.Errorf("fields = %+v, want Limit, WaitMs and Since", got)
+	}
+	if status := callStatus(t, h, inimcloud.MethodGetEventsLatest, map[string]any{"DeviceId": 1}); status != int(ErrUnknownDevice) {
+		t.Errorf("unknown device: Status = %d", status)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/lacherogwu/ha-inim_cloud/mockapi/inimcloud"
+)
+
+// HandleExportHa serves a Home Assistant package modelling the current
+// devices, to bootstrap a test instance by hand: a manual alarm control
+// panel per device, and template entities for the rest of the device, a
+// binary sensor per zone showing whether it is open and a switch per output.
+func HandleExportHa(w http.ResponseWriter, r *http.Request) {
+	stateMu.Lock()
+	views := DeviceViews()
+	stateMu.Unlock()
+
+	b := &strings.Builder{}
+	b.WriteString("# Generated by the Inim Cloud mock from its current devices.\n")
+	b.WriteString("alarm_control_panel:\n")
+	for _, view := range views {
+		b.WriteString("  - platform: manual\n")
+		fmt.Fprintf(b, "    name: %s\n", strconv.Quote(view.Name))
+		fmt.Fprintf(b, "    unique_id: inim_cloud_%d\n", view.DeviceId)
+		b.WriteString("    arming_time: 0\n")
+		b.WriteString("    delay_time: 0\n")
+	}
+
+	zones, outputs := &strings.Builder{}, &strings.Builder{}
+	for _, view := range views {
+		for _, zone := range view.Zones {
+			state := "off"
+			if zone.Status == inimcloud.ZoneOpen {
+				state = "on"
+			}
+			fmt.Fprintf(zones, "      - name: %s\n", strconv.Quote(view.Name+" "+zone.Name))
+			fmt.Fprintf(zones, "        unique_id: inim_cloud_%d_zone_%d\n", view.DeviceId, zone.ZoneId)
+			fmt.Fprintf(zones, "        state: %q\n", state)
+		}
+		for _, output := range view.Outputs {
+			fmt.Fprintf(outputs, "      - name: %s\n", strconv.Quote(view.Name+" "+output.Name))
+			fmt.Fprintf(outputs, "        unique_id: inim_cloud_%d_output_%d\n", view.DeviceId, output.OutputId)
+			outputs.WriteString("        turn_on: []\n")
+			outputs.WriteString("        turn_off: []\n")
+		}
+	}
+	if zones.Len() > 0 || outputs.Len() > 0 {
+		b.WriteString("template:\n")
+	}
+	if zones.Len() > 0 {
+		b.WriteString("  - binary_sensor:\n")
+		b.WriteString(zones.String())
+	}
+	if outputs.Len() > 0 {
+		b.WriteString("  - switch:\n")
+		b.WriteString(outputs.String())
+	}
+
+	w.Header().Set("Content-Type", "application/yaml")
+	w.Write([]byte(b.String()))
+}
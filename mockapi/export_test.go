@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/lacherogwu/ha-inim_cloud/mockapi/inimcloud"
+)
+
+// parseExport reads the export line by line, which is enough for the block
+// structure it emits, and returns the entries of each entity list by
+// domain: alarm_control_panel, and binary_sensor and switch from under
+// template.
+func parseExport(t *testing.T, yaml string) map[string][]map[string]string {
+	t.Helper()
+	entries := map[string][]map[string]string{}
+	domain := ""
+	scanner := bufio.NewScanner(strings.NewReader(yaml))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		text := strings.TrimLeft(line, " ")
+		indent := len(line) - len(text)
+		text, item := strings.CutPrefix(text, "- ")
+
+		key, value, ok := strings.Cut(text, ": ")
+		if !ok {
+			// A list header: a top-level key, or a domain under template.
+			name, isHeader := strings.CutSuffix(text, ":")
+			topLevel := indent == 0 && !item
+			if !isHeader || topLevel != (name == "alarm_control_panel" || name == "template") {
+				t.Fatalf("unexpected line %q", line)
+			}
+			domain = name
+			continue
+		}
+		if item {
+			entries[domain] = append(entries[domain], map[string]string{})
+		} else if len(entries[domain]) == 0 {
+			t.Fatalf("line %q outside any entry", line)
+		}
+		if strings.HasPrefix(value, `"`) {
+			unquoted, err := strconv.Unquote(value)
+			if err != nil {
+				t.Fatalf("line %q: invalid quoted value: %v", line, err)
+			}
+			value = unquoted
+		}
+		list := entries[domain]
+		list[len(list)-1][key] = value
+	}
+	return entries
+}
+
+func exportHa(t *testing.T, h http.Handler) map[string][]map[string]string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/export/ha.yaml", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/yaml" {
+		t.Fatalf("GET /export/ha.yaml = %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	return parseExport(t, rec.Body.String())
+}
+
+func TestExportHaPanelPerDevice(t *testing.T) {
+	h := newTestMux(t)
+	devices, active := manyDevices(2)
+	devices[1].Name = `Garage: "back"`
+	store = NewMemoryStore(devices, active)
+
+	panels := exportHa(t, h)["alarm_control_panel"]
+	if len(panels) != len(devices) {
+		t.Fatalf("%d panels for %d devices: %v", len(panels), len(devices), panels)
+	}
+	for i, device := range devices {
+		panel := panels[i]
+		if panel["platform"] != "manual" || panel["name"] != device.Name ||
+			panel["unique_id"] != "inim_cloud_"+strconv.Itoa(device.DeviceId) {
+			t.Errorf("panel %d = %v, want one for %q", i, panel, device.Name)
+		}
+	}
+}
+
+func TestExportHaZonesAndOutputs(t *testing.T) {
+	h := newTestMux(t)
+	device := defaultDevices[0]
+	device.Zones = []inimcloud.Zone{
+		{ZoneId: 1, Type: 1, Name: "Front door", Areas: []int{1}, Visibility: true},
+		{ZoneId: 2, Type: 2, Name: "Hall", Areas: []int{1}, Visibility: true},
+	}
+	device.Outputs = []inimcloud.Output{{OutputId: 1, Name: "Siren"}}
+	store = NewMemoryStore([]Device{device}, map[int]int{testDevice: 1})
+	post(t, h, "/admin/devices/545002/zones/2/open", "")
+
+	export := exportHa(t, h)
+	sensors := export["binary_sensor"]
+	if len(sensors) != 2 {
+		t.Fatalf("%d binary sensors for 2 zones: %v", len(sensors), export)
+	}
+	for i, want := range []struct{ name, state string }{{"Front door", "off"}, {"Hall", "on"}} {
+		sensor := sensors[i]
+		if sensor["name"] != device.Name+" "+want.name || sensor["state"] != want.state ||
+			sensor["unique_id"] != "inim_cloud_545002_zone_"+strconv.Itoa(i+1) {
+			t.Errorf("binary sensor %d = %v, want %s %s", i, sensor, want.name, want.state)
+		}
+	}
+	switches := export["switch"]
+	if len(switches) != 1 || switches[0]["name"] != device.Name+" Siren" || switches[0]["unique_id"] != "inim_cloud_545002_output_1" {
+		t.Errorf("switches = %v, want one for the siren", switches)
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/lacherogwu/ha-inim_cloud/mockapi/inimcloud"
+)
+
+// Panels raise faults such as a low battery or a tamper, which stay active
+// until acknowledged with AckFault. Faults are injected through
+// POST /admin/devices/{id}/faults.
+
+type FaultType string
+
+const (
+	FaultLowBattery FaultType = "LowBattery"
+	FaultTamper     FaultType = "Tamper"
+	FaultComms      FaultType = "CommsFault"
+)
+
+var faultTypes = []FaultType{FaultLowBattery, FaultTamper, FaultComms}
+
+type Fault struct {
+	FaultId  int       `json:"FaultId"`
+	Type     FaultType `json:"Type"`
+	RaisedAt time.Time `json:"RaisedAt"`
+}
+
+// RaiseFault adds an active fault to a device. The caller must hold stateMu.
+func RaiseFault(deviceId int, faultType FaultType) Fault {
+	fault := store.AddFault(deviceId, Fault{Type: faultType, RaisedAt: clock.Now()})
+	RecordEvent(Event{DeviceId: deviceId, Type: EventFaultRaised, FaultId: &fault.FaultId})
+	return fault
+}
+
+func HandleGetFaults(w http.ResponseWriter, reqData *inimcloud.Request) {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+
+	// Without a DeviceId, faults are listed for every device.
+	deviceIds := []int{}
+	if deviceId, ok := paramInt(reqData.Params, "DeviceId"); ok {
+		if _, found := store.Device(deviceId); !found {
+			WriteStatus(w, ErrUnknownDevice, "Device not found")
+			return
+		}
+		deviceIds = append(deviceIds, deviceId)
+	} else {
+		for _, device := range store.Devices() {
+			deviceIds = append(deviceIds, device.DeviceId)
+		}
+	}
+
+	list := []map[string]any{}
+	for _, deviceId := range deviceIds {
+		list = append(list, map[string]any{
+			"DeviceId": deviceId,
+			"Faults":   append([]Fault{}, store.Faults(deviceId)...),
+		})
+	}
+	WriteJson(w, map[string]any{"Devices": list})
+}
+
+func HandleAckFault(w http.ResponseWriter, reqData *inimcloud.Request) {
+	v := validateParams(reqData.Params)
+	deviceId := v.Int("DeviceId")
+	faultId := v.Int("FaultId")
+	if !v.Check(w) {
+		return
+	}
+
+	stateMu.Lock()
+	defer stateMu.Unlock()
+
+	if !store.RemoveFault(deviceId, faultId) {
+		WriteStatus(w, ErrUnknownFault, "Fault not found")
+		return
+	}
+	WriteJson(w, map[string]any{})
+}
+
+func HandleInjectFault(w http.ResponseWriter, r *http.Request) {
+	deviceId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid device id")
+		return
+	}
+	stateMu.Lock()
+	_, found := store.Device(deviceId)
+	stateMu.Unlock()
+	if !found {
+		WriteError(w, http.StatusNotFound, "Device not found")
+		return
+	}
+
+	body := struct {
+		Type FaultType `json:"Type"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || !slices.Contains(faultTypes, body.Type) {
+		WriteError(w, http.StatusBadRequest, "Invalid fault type")
+		return
+	}
+
+	stateMu.Lock()
+	fault := RaiseFault(deviceId, body.Type)
+	stateMu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(fault); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/lacherogwu/ha-inim_cloud/mockapi/inimcloud"
+)
+
+func getFaults(t *testing.T, h http.Handler) []Fault {
+	t.Helper()
+	data := struct{ Devices []struct{ Faults []Fault } }{}
+	mustCall(t, h, inimcloud.MethodGetFaults, map[string]any{"DeviceId": testDevice}, &data)
+	return data.Devices[0].Faults
+}
+
+func TestFaultsRaiseAndAck(t *testing.T) {
+	h := newTestMux(t)
+	if list := getFaults(t, h); len(list) != 0 {
+		t.Fatalf("faults at start = %+v", list)
+	}
+
+	rec := post(t, h, "/admin/devices/545002/faults", `{"Type": "LowBattery"}`)
+	raised := Fault{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &raised); err != nil || raised.Type != FaultLowBattery {
+		t.Fatalf("POST faults = %d %s", rec.Code, rec.Body)
+	}
+	post(t, h, "/admin/devices/545002/faults", `{"Type": "Tamper"}`)
+	if list := getFaults(t, h); len(list) != 2 || list[0].FaultId != raised.FaultId {
+		t.Fatalf("faults = %+v", list)
+	}
+
+	mustCall(t, h, inimcloud.MethodAckFault, map[string]any{"DeviceId": testDevice, "FaultId": raised.FaultId}, nil)
+	if list := getFaults(t, h); len(list) != 1 || list[0].Type != FaultTamper {
+		t.Errorf("faults after the ack = %+v", list)
+	}
+	if status := callStatus(t, h, inimcloud.MethodAckFault, map[string]any{"DeviceId": testDevice, "FaultId": raised.FaultId}); status != int(ErrUnknownFault) {
+		t.Errorf("second ack = %d, want %d", status, ErrUnknownFault)
+	}
+}
+
+func TestInjectFaultValidation(t *testing.T) {
+	h := newTestMux(t)
+	if rec := post(t, h, "/admin/devices/545002/faults", `{"Type": "Flood"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("unknown fault type = %d, want 400", rec.Code)
+	}
+	if rec := post(t, h, "/admin/devices/1/faults", `{"Type": "Tamper"}`); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown device = %d, want 404", rec.Code)
+	}
+}
+
+func TestGetFaultsUnknownDevice(t *testing.T) {
+	h := newTestMux(t)
+	if status := callStatus(t, h, inimcloud.MethodGetFaults, map[string]any{"DeviceId": 1}); status != int(ErrUnknownDevice) {
+		t.Errorf("GetFaults of an unknown device = %d, want %d", status, ErrUnknownDevice)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/lacherogwu/ha-inim_cloud/mockapi/inimcloud"
+)
+
+// A firmware update, started through POST /admin/devices/{id}/firmware-update,
+// puts the device in an updating state for its duration. Progress advances
+// with time, write methods are refused meanwhile, and once done the device
+// reports the new firmware version. Starting and completing an update both
+// bump the device's version and are recorded as events.
+
+type FirmwareUpdate struct {
+	Version  string        `json:"Version"`
+	Start    time.Time     `json:"Start"`
+	Duration time.Duration `json:"Duration"`
+}
+
+// Firmware returns the device's firmware state at now. The caller must hold
+// stateMu.
+func Firmware(device Device, now time.Time) inimcloud.FirmwareStatus {
+	deviceId := device.DeviceId
+	version, ok := store.FirmwareVersion(deviceId)
+	if !ok {
+		version = device.FirmwareVersion
+	}
+	update, ok := store.FirmwareUpdate(deviceId)
+	if !ok {
+		return inimcloud.FirmwareStatus{Version: version, Progress: 100}
+	}
+	// An update stays in progress until its completion has run.
+	elapsed := now.Sub(update.Start)
+	return inimcloud.FirmwareStatus{
+		Version:  version,
+		Updating: true,
+		Progress: min(int(100*elapsed/update.Duration), 99),
+	}
+}
+
+// StartFirmwareUpdate puts the device in the updating state and schedules
+// the update's completion. The caller must hold stateMu.
+func StartFirmwareUpdate(deviceId int, update FirmwareUpdate) {
+	store.SetFirmwareUpdate(deviceId, update)
+	store.MarkChanged(deviceId)
+	RecordEvent(Event{DeviceId: deviceId, Type: EventFirmwareUpdateStarted, FirmwareVersion: &update.Version})
+	scheduleFirmwareCompletion(deviceId, update)
+}
+
+// scheduleFirmwareCompletion installs update once its time is up. The
+// caller must hold stateMu.
+func scheduleFirmwareCompletion(deviceId int, update FirmwareUpdate) {
+	scheduledAt := restores
+	clock.AfterFunc(update.Start.Add(update.Duration).Sub(clock.Now()), func() {
+		stateMu.Lock()
+		defer stateMu.Unlock()
+
+		// The update may have been replaced by a restore since.
+		if restores != scheduledAt {
+			return
+		}
+		store.SetFirmwareVersion(deviceId, update.Version)
+		store.ClearFirmwareUpdate(deviceId)
+		store.MarkChanged(deviceId)
+		RecordEvent(Event{DeviceId: deviceId, Type: EventFirmwareUpdated, FirmwareVersion: &update.Version})
+	})
+}
+
+func HandleFirmwareUpdate(w http.ResponseWriter, r *http.Request) {
+	deviceId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid device id")
+		return
+	}
+
+	body := struct {
+		Version  string `json:"Version"`
+		Duration string `json:"Duration"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Version == "" {
+		WriteError(w, http.StatusBadRequest, "Invalid firmware update")
+		return
+	}
+	duration, err := time.ParseDuration(body.Duration)
+	if err != nil || duration <= 0 {
+		WriteError(w, http.StatusBadRequest, "Invalid Duration")
+		return
+	}
+
+	stateMu.Lock()
+	defer stateMu.Unlock()
+
+	device, found := store.Device(deviceId)
+	if !found {
+		WriteError(w, http.StatusNotFound, "Device not found")
+		return
+	}
+	now := clock.Now()
+	if Firmware(device, now).Updating {
+		WriteError(w, http.StatusConflict, "Update already in progress")
+		return
+	}
+	StartFirmwareUpdate(deviceId, FirmwareUpdate{Version: body.Version, Start: now, Duration: duration})
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(Firmware(device, now)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/lacherogwu/ha-inim_cloud/mockapi/inimcloud"
+)
+
+func TestFirmwareUpdateProgress(t *testing.T) {
+	c := useFakeClock(t)
+	h := newTestMux(t)
+
+	rec := post(t, h, "/admin/devices/545002/firmware-update", `{"Version":"6.08","Duration":"10s"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST firmware-update = %d %s", rec.Code, rec.Body)
+	}
+	if rec := post(t, h, "/admin/devices/545002/firmware-update", `{"Version":"6.09","Duration":"1s"}`); rec.Code != http.StatusConflict {
+		t.Errorf("second update while updating = %d, want 409", rec.Code)
+	}
+
+	c.Advance(5 * time.Second)
+	want := inimcloud.FirmwareStatus{Version: "6.07", Updating: true, Progress: 50}
+	if got := getDevice(t, h).Firmware; got != want {
+		t.Errorf("halfway: Firmware = %+v, want %+v", got, want)
+	}
+	if status := callStatus(t, h, inimcloud.MethodActivateScenario, activate(2)); status != int(ErrDeviceUpdating) {
+		t.Errorf("activation while updating: Status = %d, want %d", status, ErrDeviceUpdating)
+	}
+
+	c.Advance(5 * time.Second)
+	want = inimcloud.FirmwareStatus{Version: "6.08", Progress: 100}
+	if got := getDevice(t, h).Firmware; got != want {
+		t.Errorf("after update: Firmware = %+v, want %+v", got, want)
+	}
+	mustCall(t, h, inimcloud.MethodActivateScenario, activate(2), nil)
+}
+
+func TestFirmwareUpdateIsAChange(t *testing.T) {
+	c := useFakeClock(t)
+	h := newTestMux(t)
+	before := getDevice(t, h).Version
+
+	post(t, h, "/admin/devices/545002/firmware-update", `{"Version":"6.08","Duration":"10s"}`)
+	if got := getDevice(t, h).Version; got != before+1 {
+		t.Errorf("version after the update started = %d, want %d", got, before+1)
+	}
+	c.Advance(10 * time.Second)
+	if got := getDevice(t, h).Version; got != before+2 {
+		t.Errorf("version after the update completed = %d, want %d", got, before+2)
+	}
+
+	events := getEvents(t, h, nil).Events
+	if got := eventTypes(events); !slices.Equal(got, []EventType{EventFirmwareUpdateStarted, EventFirmwareUpdated}) {
+		t.Fatalf("events = %v, want the update started and completed", got)
+	}
+	if version := events[1].FirmwareVersion; version == nil || *version != "6.08" {
+		t.Errorf("FirmwareVersion of the completion = %v, want 6.08", version)
+	}
+}
+
+func TestFirmwareUpdateInvalid(t *testing.T) {
+	h := newTestMux(t)
+	tests := []struct {
+		path, body string
+		want       int
+	}{
+		{"/admin/devices/x/firmware-update", `{"Version":"6.08","Duration":"1s"}`, http.StatusBadRequest},
+		{"/admin/devices/545002/firmware-update", `{"Duration":"1s"}`, http.StatusBadRequest},
+		{"/admin/devices/545002/firmware-update", `{"Version":"6.08","Duration":"0s"}`, http.StatusBadRequest},
+		{"/admin/devices/1/firmware-update", `{"Version":"6.08","Duration":"1s"}`, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if rec := post(t, h, tt.path, tt.body); rec.Code != tt.want {
+			t.Errorf("POST %s %s = %d, want %d", tt.path, tt.body, rec.Code, tt.want)
+		}
+	}
+}
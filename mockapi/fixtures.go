@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// A fixtures file replaces the built-in device catalog, so multi-panel
+// accounts can be simulated without recompiling, for example:
+//
+//	{"Devices": [{
+//		"DeviceId": 1, "Name": "Home", "ActiveScenario": 1,
+//		"Scenarios": [{"ScenarioId": 0, "Name": "ARM"}, {"ScenarioId": 1, "Name": "DISARM"}],
+//		"Ares": [{"AresId": 1, "Name": "Ground floor"}],
+//		"Zones": [{"ZoneId": 1, "Type": 1, "Name": "Front door", "Areas": [1]}]
+//	}]}
+//
+// Devices, areas and zones are spelled as GetDevicesExtended serves them.
+// Zones are visible unless their Visibility says otherwise, and a scenario
+// arms the areas its Areas lists, or all of them. A device's Account limits
+// it to one account's sessions. Only JSON is read; the mock has no YAML
+// dependency.
+var configFile = flag.String("config", "", "JSON fixtures file describing the devices to simulate")
+
+type Fixtures struct {
+	Devices []FixtureDevice `json:"Devices"`
+}
+
+// FixtureDevice is a device along with its initial state.
+type FixtureDevice struct {
+	Device
+	ActiveScenario int `json:"ActiveScenario"`
+}
+
+// LoadFixtures reads a fixtures file and returns the devices it describes
+// with their initial scenarios.
+func LoadFixtures(path string) ([]Device, map[int]int, error) {
+	if ext := filepath.Ext(path); ext == ".yaml" || ext == ".yml" {
+		return nil, nil, errors.New("YAML fixtures are not supported, use JSON")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fixtures := Fixtures{}
+	if err := json.Unmarshal(data, &fixtures); err != nil {
+		return nil, nil, err
+	}
+	if len(fixtures.Devices) == 0 {
+		return nil, nil, errors.New("no devices")
+	}
+	if err := defaultVisibility(data, fixtures.Devices); err != nil {
+		return nil, nil, err
+	}
+
+	devices := make([]Device, 0, len(fixtures.Devices))
+	active := make(map[int]int, len(fixtures.Devices))
+	for _, fixture := range fixtures.Devices {
+		device := fixture.Device
+		if err := validateFixture(device, fixture.ActiveScenario); err != nil {
+			return nil, nil, fmt.Errorf("device %d: %w", device.DeviceId, err)
+		}
+		if _, dup := active[device.DeviceId]; dup {
+			return nil, nil, fmt.Errorf("device %d: duplicate DeviceId", device.DeviceId)
+		}
+		devices = append(devices, device)
+		active[device.DeviceId] = fixture.ActiveScenario
+	}
+	return devices, active, nil
+}
+
+// defaultVisibility makes visible the zones of devices whose fixture data
+// leaves Visibility out.
+func defaultVisibility(data []byte, devices []FixtureDevice) error {
+	given := struct {
+		Devices []struct {
+			Zones []struct {
+				Visibility *bool `json:"Visibility"`
+			} `json:"Zones"`
+		} `json:"Devices"`
+	}{}
+	if err := json.Unmarshal(data, &given); err != nil {
+		return err
+	}
+	for i, device := range given.Devices {
+		for j, zone := range device.Zones {
+			if zone.Visibility == nil {
+				devices[i].Zones[j].Visibility = true
+			}
+		}
+	}
+	return nil
+}
+
+func validateFixture(device Device, activeScenario int) error {
+	if len(device.Scenarios) == 0 {
+		return errors.New("no scenarios")
+	}
+	if _, ok := device.Scenario(activeScenario); !ok {
+		return fmt.Errorf("unknown ActiveScenario %d", activeScenario)
+	}
+	for _, scenario := range device.Scenarios {
+		for _, areaId := range scenario.Areas {
+			if _, ok := device.Area(areaId); !ok {
+				return fmt.Errorf("scenario %d: unknown area %d", scenario.ScenarioId, areaId)
+			}
+		}
+	}
+	for _, scenarioId := range device.DisarmScenarios {
+		if _, ok := device.Scenario(scenarioId); !ok {
+			return fmt.Errorf("unknown disarm scenario %d", scenarioId)
+		}
+	}
+	for _, zone := range device.Zones {
+		if len(zone.Areas) == 0 {
+			return fmt.Errorf("zone %d: no areas", zone.ZoneId)
+		}
+		for _, areaId := range zone.Areas {
+			if _, ok := device.Area(areaId); !ok {
+				return fmt.Errorf("zone %d: unknown area %d", zone.ZoneId, areaId)
+			}
+		}
+	}
+	return nil
+}
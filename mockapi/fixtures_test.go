@@ -0,0 +1,91 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lacherogwu/ha-inim_cloud/mockapi/inimcloud"
+)
+
+const twoPanels = `{"Devices": [
+	{
+		"DeviceId": 1, "Name": "Home", "ActiveScenario": 1,
+		"Scenarios": [{"ScenarioId": 0, "Name": "ARM"}, {"ScenarioId": 1, "Name": "DISARM"}],
+		"Ares": [{"AresId": 1, "Name": "Ground floor"}],
+		"Zones": [
+			{"ZoneId": 1, "Type": 1, "Name": "Front door", "Areas": [1]},
+			{"ZoneId": 2, "Type": 1, "Name": "Tamper", "Areas": [1], "Visibility": false}
+		]
+	},
+	{
+		"DeviceId": 2, "Name": "Office", "ActiveScenario": 0,
+		"Scenarios": [{"ScenarioId": 0, "Name": "OFF"}, {"ScenarioId": 5, "Name": "NIGHT"}],
+		"Ares": [{"AresId": 1, "Name": "Floor"}]
+	}
+]}`
+
+func writeFixtures(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestFixturesDriveHandlers(t *testing.T) {
+	h := newTestMux(t)
+	devices, active, err := LoadFixtures(writeFixtures(t, "fixtures.json", twoPanels))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store = NewMemoryStore(devices, active)
+
+	mustCall(t, h, inimcloud.MethodActivateScenario, map[string]any{"DeviceId": 2, "ScenarioId": 5}, nil)
+	data := struct{ Devices []inimcloud.DeviceView }{}
+	mustCall(t, h, inimcloud.MethodGetDevicesExtended, nil, &data)
+	if len(data.Devices) != 2 {
+		t.Fatalf("%d devices, want 2", len(data.Devices))
+	}
+	home, office := data.Devices[0], data.Devices[1]
+	if home.ActiveScenario != 1 || office.ActiveScenario != 5 || office.ActiveScenarioName != "NIGHT" {
+		t.Errorf("scenarios = %d and %d %q, want 1 and 5 NIGHT", home.ActiveScenario, office.ActiveScenario, office.ActiveScenarioName)
+	}
+	if !home.Zones[0].Visibility || home.Zones[1].Visibility {
+		t.Errorf("zone visibility = %v, %v, want the default true and the given false", home.Zones[0].Visibility, home.Zones[1].Visibility)
+	}
+	if status := callStatus(t, h, inimcloud.MethodActivateScenario, activate(1)); status != int(ErrUnknownDevice) {
+		t.Errorf("built-in device still known: Status = %d", status)
+	}
+}
+
+func TestLoadFixturesErrors(t *testing.T) {
+	device := func(fields string) string {
+		return `{"Devices": [{"DeviceId": 1, "ActiveScenario": 0, "Ares": [{"AresId": 1}], ` + fields + `}]}`
+	}
+	tests := []struct {
+		name, content, want string
+	}{
+		{"no devices", `{"Devices": []}`, "no devices"},
+		{"no scenarios", device(`"Scenarios": []`), "no scenarios"},
+		{"active scenario", device(`"Scenarios": [{"ScenarioId": 3}]`), "unknown ActiveScenario 0"},
+		{"scenario area", device(`"Scenarios": [{"ScenarioId": 0, "Areas": [2]}]`), "scenario 0: unknown area 2"},
+		{"disarm scenario", device(`"Scenarios": [{"ScenarioId": 0}], "DisarmScenarios": [4]`), "unknown disarm scenario 4"},
+		{"zone without areas", device(`"Scenarios": [{"ScenarioId": 0}], "Zones": [{"ZoneId": 7}]`), "zone 7: no areas"},
+		{"zone area", device(`"Scenarios": [{"ScenarioId": 0}], "Zones": [{"ZoneId": 7, "Areas": [9]}]`), "zone 7: unknown area 9"},
+		{"duplicate", strings.Replace(twoPanels, `"DeviceId": 2`, `"DeviceId": 1`, 1), "duplicate DeviceId"},
+		{"invalid JSON", `{"Devices": [`, "unexpected end"},
+	}
+	for _, tt := range tests {
+		_, _, err := LoadFixtures(writeFixtures(t, "fixtures.json", tt.content))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: err = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+
+	if _, _, err := LoadFixtures(writeFixtures(t, "fixtures.yaml", twoPanels)); err == nil {
+		t.Error("YAML fixtures accepted")
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// Flaky mode roughens up successful replies the way the real cloud
+// occasionally does: a Status sent as a string, an unexpected extra field or
+// a non-critical field of the wrong type. The Status and Data fields are
+// always present so a client can still make sense of the reply.
+var (
+	flakyRate = flag.Float64("flaky-rate", 0, "fraction of successful replies to roughen up (0 to 1)")
+	flakySeed = flag.Uint64("flaky-seed", 0, "seed for flaky mode and fault injection (0 seeds from the clock)")
+)
+
+var (
+	flakyMu  sync.Mutex
+	flakyRng *rand.Rand
+)
+
+var flakyMutations = []func(resData map[string]any){
+	func(resData map[string]any) {
+		resData[*statusField] = "0"
+	},
+	func(resData map[string]any) {
+		resData["Unexpected"] = map[string]any{"Note": "field unknown to clients"}
+	},
+	func(resData map[string]any) {
+		resData["ErrMsg"] = 0
+	},
+}
+
+// Roughen applies a random flaky mutation to a successful envelope, at the
+// configured rate.
+func Roughen(resData map[string]any) {
+	if !chance(*flakyRate) {
+		return
+	}
+	flakyMutations[randomN(len(flakyMutations))](resData)
+}
+
+// chance reports true with probability rate. Like every random choice of
+// the mock, it draws from the generator seeded by -flaky-seed.
+func chance(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+	flakyMu.Lock()
+	defer flakyMu.Unlock()
+	return rng().Float64() < rate
+}
+
+// randomN returns a random int in [0, n).
+func randomN(n int) int {
+	flakyMu.Lock()
+	defer flakyMu.Unlock()
+	return rng().IntN(n)
+}
+
+// rng returns the shared generator. The caller must hold flakyMu.
+func rng() *rand.Rand {
+	if flakyRng == nil {
+		seed := *flakySeed
+		if seed == 0 {
+			seed = uint64(time.Now().UnixNano())
+		}
+		flakyRng = rand.New(rand.NewPCG(seed, seed))
+	}
+	return flakyRng
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// reseed makes the next random choice start over from seed.
+func reseed(t *testing.T, seed uint64) {
+	t.Helper()
+	setFlag(t, flakySeed, seed)
+	resetRng := func() {
+		flakyMu.Lock()
+		flakyRng = nil
+		flakyMu.Unlock()
+	}
+	resetRng()
+	t.Cleanup(resetRng)
+}
+
+// flakyShapes calls GetSystemTime n times and describes each reply by its
+// fields other than Data, which holds the time.
+func flakyShapes(t *testing.T, h http.Handler, n int) []string {
+	t.Helper()
+	shapes := make([]string, 0, n)
+	for range n {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?req="+url.QueryEscape(`{"Method":"GetSystemTime"}`), nil))
+
+		body := map[string]json.RawMessage{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("reply %q is not JSON: %v", rec.Body, err)
+		}
+		if body["Status"] == nil || body["Data"] == nil {
+			t.Errorf("reply %s lost Status or Data", rec.Body)
+		}
+		fields := []string{}
+		for field, value := range body {
+			if field != "Data" {
+				fields = append(fields, field+"="+string(value))
+			}
+		}
+		slices.Sort(fields)
+		shapes = append(shapes, strings.Join(fields, ","))
+	}
+	return shapes
+}
+
+func TestFlakyRateOneRoughensEveryReply(t *testing.T) {
+	setFlag(t, flakyRate, 1)
+	reseed(t, 42)
+	h := newTestMux(t)
+
+	for _, shape := range flakyShapes(t, h, 30) {
+		if shape == "Status=0" {
+			t.Errorf("reply left untouched at rate 1")
+		}
+	}
+}
+
+func TestFlakyRateZeroLeavesRepliesAlone(t *testing.T) {
+	h := newTestMux(t)
+	for _, shape := range flakyShapes(t, h, 30) {
+		if shape != "Status=0" {
+			t.Errorf("reply roughened without -flaky-rate: %s", shape)
+		}
+	}
+}
+
+func TestFlakySeedIsReproducible(t *testing.T) {
+	setFlag(t, flakyRate, 0.5)
+	h := newTestMux(t)
+
+	reseed(t, 7)
+	first := flakyShapes(t, h, 30)
+	reseed(t, 7)
+	second := flakyShapes(t, h, 30)
+	if !slices.Equal(first, second) {
+		t.Errorf("seed 7 gave %v, then %v", first, second)
+	}
+}
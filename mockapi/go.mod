@@ -0,0 +1,3 @@
+module github.com/lacherogwu/ha-inim_cloud/mockapi
+
+go 1.24
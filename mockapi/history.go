@@ -0,0 +1,67 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+	"time"
+
+	"github.com/lacherogwu/ha-inim_cloud/mockapi/inimcloud"
+)
+
+// Every scenario change is recorded per device along with who made it, so a
+// panel's timeline can be rebuilt with GetScenarioHistory. Only the last
+// -history-size entries are kept for each device.
+var historySize = flag.Int("history-size", 50, "number of scenario changes kept per device")
+
+type HistoryEntry struct {
+	ScenarioId int       `json:"ScenarioId"`
+	At         time.Time `json:"At"`
+	Actor      string    `json:"Actor"`
+}
+
+// RequestActor names the caller of a request in the history: its client id
+// when it sent one, else the client its session was issued to. Tokens are
+// never recorded; a session without a client id is named by its opaque id.
+func RequestActor(reqData *inimcloud.Request) string {
+	if reqData.ClientId != "" {
+		return "client:" + reqData.ClientId
+	}
+	session, ok := LookupSession(reqData.Token)
+	switch {
+	case !ok:
+		return "anonymous"
+	case session.ClientId != "":
+		return "client:" + session.ClientId
+	}
+	return "session:" + session.Id
+}
+
+// RecordHistory appends a scenario change to the device's history. The
+// caller must hold stateMu.
+func RecordHistory(deviceId, scenarioId int, actor string) {
+	store.AppendHistory(deviceId, HistoryEntry{
+		ScenarioId: scenarioId,
+		At:         clock.Now(),
+		Actor:      actor,
+	}, *historySize)
+}
+
+func HandleGetScenarioHistory(w http.ResponseWriter, reqData *inimcloud.Request) {
+	v := validateParams(reqData.Params)
+	deviceId := v.Int("DeviceId")
+	if !v.Check(w) {
+		return
+	}
+	stateMu.Lock()
+	defer stateMu.Unlock()
+
+	if _, found := store.Device(deviceId); !found {
+		WriteStatus(w, ErrUnknownDevice, "Device not found")
+		return
+	}
+
+	WriteJson(w, map[string]any{
+		"DeviceId": deviceId,
+		"History":  append([]HistoryEntry{}, store.History(deviceId)...),
+	})
+}
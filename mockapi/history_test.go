@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/lacherogwu/ha-inim_cloud/mockapi/inimcloud"
+)
+
+func getHistory(t *testing.T, h http.Handler) []HistoryEntry {
+	t.Helper()
+	data := struct{ History []HistoryEntry }{}
+	mustCall(t, h, inimcloud.MethodGetScenarioHistory, map[string]any{"DeviceId": testDevice}, &data)
+	return data.History
+}
+
+func TestHistoryRecordsActors(t *testing.T) {
+	h := newTestMux(t)
+	withClient := NewSession("ha-1")
+	anonymous := NewSession("")
+
+	callApi(t, h, inimcloud.Request{Method: inimcloud.MethodActivateScenario, ClientId: "explicit", Params: activate(2)})
+	callApi(t, h, inimcloud.Request{Method: inimcloud.MethodActivateScenario, Token: withClient.Token, Params: activate(0)})
+	callApi(t, h, inimcloud.Request{Method: inimcloud.MethodActivateScenario, Token: anonymous.Token, Params: activate(1)})
+	callApi(t, h, inimcloud.Request{Method: inimcloud.MethodActivateScenario, Token: "not-a-session", Params: activate(2)})
+	post(t, h, "/admin/devices/545002/scenario", `{"ScenarioId": 1}`)
+
+	want := []struct {
+		scenarioId int
+		actor      string
+	}{
+		{2, "client:explicit"},
+		{0, "client:ha-1"},
+		{1, "session:" + anonymous.Id},
+		{2, "anonymous"},
+		{1, keypadActor},
+	}
+	entries := getHistory(t, h)
+	if len(entries) != len(want) {
+		t.Fatalf("history = %+v", entries)
+	}
+	for i, entry := range entries {
+		if entry.ScenarioId != want[i].scenarioId || entry.Actor != want[i].actor {
+			t.Errorf("entry %d = %+v, want scenario %d by %s", i, entry, want[i].scenarioId, want[i].actor)
+		}
+		for _, token := range []string{withClient.Token, anonymous.Token, "not-a-session"} {
+			if strings.Contains(entry.Actor, token) {
+				t.Errorf("entry %d leaks a token: %s", i, entry.Actor)
+			}
+		}
+	}
+}
+
+func TestHistoryIsBounded(t *testing.T) {
+	setFlag(t, historySize, 3)
+	h := newTestMux(t)
+	for _, scenarioId := range []int{0, 1, 2, 0, 1} {
+		mustCall(t, h, inimcloud.MethodActivateScenario, activate(scenarioId), nil)
+	}
+	entries := getHistory(t, h)
+	if len(entries) != 3 || entries[0].ScenarioId != 2 || entries[2].ScenarioId != 1 {
+		t.Errorf("history = %+v, want the last 3 changes", entries)
+	}
+}
+
+func TestHistoryUnknownDevice(t *testing.T) {
+	h := newTestMux(t)
+	if status := callStatus(t, h, inimcloud.MethodGetScenarioHistory, map[string]any{"DeviceId": 1}); status != int(ErrUnknownDevice) {
+		t.Errorf("history of an unknown device = %d, want %d", status, ErrUnknownDevice)
+	}
+}
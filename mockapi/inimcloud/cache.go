@@ -0,0 +1,96 @@
+package inimcloud
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"sync"
+	"time"
+)
+
+// WithCache keeps successful GetDevicesExtended replies for ttl, so repeated
+// reads within it don't reach the server. Replies are cached by method and
+// params; ActivateScenario drops every reply that includes the device it
+// changes.
+func WithCache(ttl time.Duration) Option {
+	return func(c *Client) {
+		c.cache = &responseCache{ttl: ttl, entries: map[string]cacheEntry{}}
+	}
+}
+
+type responseCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	data      json.RawMessage
+	devices   []int
+	expiresAt time.Time
+}
+
+func cacheKey(method Method, params map[string]any) string {
+	// Maps are encoded with sorted keys, so equal params give equal keys.
+	key, _ := json.Marshal(params)
+	return string(method) + " " + string(key)
+}
+
+func (rc *responseCache) get(key string, now time.Time) (json.RawMessage, bool) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	entry, ok := rc.entries[key]
+	if !ok || !now.Before(entry.expiresAt) {
+		delete(rc.entries, key)
+		return nil, false
+	}
+	return entry.data, true
+}
+
+func (rc *responseCache) put(key string, data json.RawMessage, now time.Time) {
+	listed := struct {
+		Devices []struct {
+			DeviceId int `json:"DeviceId"`
+		} `json:"Devices"`
+	}{}
+	json.Unmarshal(data, &listed)
+	devices := make([]int, 0, len(listed.Devices))
+	for _, device := range listed.Devices {
+		devices = append(devices, device.DeviceId)
+	}
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.entries[key] = cacheEntry{data: data, devices: devices, expiresAt: now.Add(rc.ttl)}
+}
+
+// invalidate drops the replies that include the device.
+func (rc *responseCache) invalidate(deviceId int) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	for key, entry := range rc.entries {
+		if slices.Contains(entry.devices, deviceId) {
+			delete(rc.entries, key)
+		}
+	}
+}
+
+// cachedCall is call for reads, answered from the cache when it is on.
+func (c *Client) cachedCall(ctx context.Context, method Method, params map[string]any, data any) error {
+	if c.cache == nil {
+		return c.call(ctx, method, params, data)
+	}
+
+	key := cacheKey(method, params)
+	raw, ok := c.cache.get(key, c.now())
+	if !ok {
+		if err := c.call(ctx, method, params, &raw); err != nil {
+			return err
+		}
+		c.cache.put(key, raw, c.now())
+	}
+	return json.Unmarshal(raw, data)
+}
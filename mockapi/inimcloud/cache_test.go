@@ -0,0 +1,60 @@
+package inimcloud
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCacheExpires(t *testing.T) {
+	s := newStubServer(t, loginReply)
+	c := NewClient(s.URL, WithCache(10*time.Second))
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+	ctx := context.Background()
+	c.RegisterClient(ctx, "user", "pass")
+
+	read := func() {
+		t.Helper()
+		if _, err := c.GetDevicesExtended(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	read()
+	now = now.Add(9 * time.Second)
+	read()
+	if n := s.count(MethodGetDevicesExtended); n != 1 {
+		t.Errorf("%d server reads within the TTL, want 1", n)
+	}
+	now = now.Add(time.Second)
+	read()
+	if n := s.count(MethodGetDevicesExtended); n != 2 {
+		t.Errorf("%d server reads once the TTL passed, want 2", n)
+	}
+}
+
+func TestCacheInvalidatesListedDevicesOnly(t *testing.T) {
+	rc := &responseCache{ttl: time.Minute, entries: map[string]cacheEntry{}}
+	now := time.Now()
+	rc.put("a", []byte(`{"Devices":[{"DeviceId":1},{"DeviceId":2}]}`), now)
+	rc.put("b", []byte(`{"Devices":[{"DeviceId":3}]}`), now)
+
+	rc.invalidate(2)
+	if _, ok := rc.get("a", now); ok {
+		t.Error("reply listing device 2 survived its invalidation")
+	}
+	if _, ok := rc.get("b", now); !ok {
+		t.Error("reply without device 2 was dropped")
+	}
+}
+
+func TestCacheKeyIgnoresParamOrder(t *testing.T) {
+	a := cacheKey(MethodGetDevicesExtended, map[string]any{"OrderBy": "name", "Order": "desc"})
+	b := cacheKey(MethodGetDevicesExtended, map[string]any{"Order": "desc", "OrderBy": "name"})
+	if a != b {
+		t.Errorf("keys differ: %q and %q", a, b)
+	}
+	if a == cacheKey(MethodGetDevicesExtended, nil) {
+		t.Error("params left out of the key")
+	}
+}
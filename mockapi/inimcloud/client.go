@@ -0,0 +1,314 @@
+package inimcloud
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Client calls the Inim Cloud API, or the mock, over its query-string JSON
+// protocol. It keeps the session token RegisterClient returns and renews it
+// with Authenticate once half its lifetime has passed. A token the server
+// refuses anyway is replaced by registering again with the same
+// credentials. A Client is safe for concurrent use.
+type Client struct {
+	baseURL      string
+	clientId     string
+	httpClient   *http.Client
+	now          func() time.Time
+	cache        *responseCache
+	secret       []byte
+	pollInterval time.Duration
+
+	mu       sync.Mutex
+	username string
+	password string
+	token    string
+	renewAt  time.Time
+}
+
+type Option func(*Client)
+
+// WithClientId sets the ClientId sent with every request.
+func WithClientId(clientId string) Option {
+	return func(c *Client) { c.clientId = clientId }
+}
+
+// WithHTTPClient sends requests through hc instead of the shared pooled
+// client.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.httpClient = hc }
+}
+
+func NewClient(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:      baseURL,
+		clientId:     "inimcloud-go",
+		httpClient:   sharedClient,
+		now:          time.Now,
+		pollInterval: defaultPollInterval,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// invalidTokenMessage is the ErrMsg of calls refused for their token. The
+// Status of that error varies, so the message is what identifies it.
+const invalidTokenMessage = "Token not valid or expired"
+
+// ErrNotRegistered is returned by calls that need a session before
+// RegisterClient succeeded.
+var ErrNotRegistered = errors.New("inimcloud: RegisterClient first")
+
+// APIError is a call the API refused. Status and Message come from the
+// error envelope; StatusCode is the HTTP status, which is 200 for most
+// refusals as the API reports them in the envelope.
+type APIError struct {
+	StatusCode int
+	Status     int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	if e.Status != 0 {
+		return fmt.Sprintf("inimcloud: status %d: %s", e.Status, e.Message)
+	}
+	return fmt.Sprintf("inimcloud: HTTP %d: %s", e.StatusCode, e.Message)
+}
+
+// IsInvalidToken reports whether err refuses a call for its token.
+func IsInvalidToken(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.Message == invalidTokenMessage
+}
+
+// RegisterClient logs in and starts a session. The credentials are kept to
+// log in again should the session be lost.
+func (c *Client) RegisterClient(ctx context.Context, username, password string) (AuthResponse, error) {
+	auth := AuthResponse{}
+	req := Request{
+		Method:   MethodRegisterClient,
+		ClientId: c.clientId,
+		Params: map[string]any{
+			"Username": username,
+			"Password": password,
+			"ClientId": c.clientId,
+		},
+	}
+	if err := c.do(ctx, req, &auth); err != nil {
+		return AuthResponse{}, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.username, c.password = username, password
+	c.setToken(auth)
+	return auth, nil
+}
+
+// Authenticate renews the current session.
+func (c *Client) Authenticate(ctx context.Context) (AuthResponse, error) {
+	c.mu.Lock()
+	token := c.token
+	c.mu.Unlock()
+	if token == "" {
+		return AuthResponse{}, ErrNotRegistered
+	}
+
+	auth := AuthResponse{}
+	req := Request{Method: MethodAuthenticate, Token: token, ClientId: c.clientId}
+	if err := c.do(ctx, req, &auth); err != nil {
+		return AuthResponse{}, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.setToken(auth)
+	return auth, nil
+}
+
+// setToken starts using a new or renewed token. The caller must hold c.mu.
+func (c *Client) setToken(auth AuthResponse) {
+	c.token = auth.Token
+	c.renewAt = time.Time{}
+	if auth.TTL > 0 {
+		c.renewAt = c.now().Add(time.Duration(auth.TTL) * time.Second / 2)
+	}
+}
+
+// GetDevicesExtended returns every device of the account with its live
+// state.
+func (c *Client) GetDevicesExtended(ctx context.Context) ([]DeviceView, error) {
+	data := struct {
+		Devices []DeviceView `json:"Devices"`
+	}{}
+	if err := c.cachedCall(ctx, MethodGetDevicesExtended, nil, &data); err != nil {
+		return nil, err
+	}
+	return data.Devices, nil
+}
+
+// Activation is the result of ActivateScenario.
+type Activation struct {
+	Version  int   `json:"Version"`
+	Sequence int64 `json:"Sequence"`
+	// ExitDelay is the countdown, in seconds, before an arming scenario
+	// takes effect. It is 0 when the scenario applied at once.
+	ExitDelay int `json:"ExitDelay"`
+}
+
+func (c *Client) ActivateScenario(ctx context.Context, deviceId, scenarioId int) (Activation, error) {
+	activation := Activation{}
+	params := map[string]any{"DeviceId": deviceId, "ScenarioId": scenarioId}
+	err := c.call(ctx, MethodActivateScenario, params, &activation)
+	// Even a failed call may have reached the panel.
+	if c.cache != nil {
+		c.cache.invalidate(deviceId)
+	}
+	if err != nil {
+		return Activation{}, err
+	}
+	return activation, nil
+}
+
+// call makes an authenticated call, renewing the session first if it is
+// due, and registering again, then retrying once, if the token is refused.
+func (c *Client) call(ctx context.Context, method Method, params map[string]any, data any) error {
+	token, err := c.sessionToken(ctx)
+	if err != nil {
+		return err
+	}
+	req := Request{Method: method, Token: token, ClientId: c.clientId, Params: params}
+	err = c.do(ctx, req, data)
+	if !IsInvalidToken(err) {
+		return err
+	}
+
+	if req.Token, err = c.login(ctx); err != nil {
+		return err
+	}
+	return c.do(ctx, req, data)
+}
+
+// sessionToken returns the token to call with, renewing it when due.
+func (c *Client) sessionToken(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	token, renewAt := c.token, c.renewAt
+	c.mu.Unlock()
+	if token == "" {
+		return "", ErrNotRegistered
+	}
+	if renewAt.IsZero() || c.now().Before(renewAt) {
+		return token, nil
+	}
+
+	auth, err := c.Authenticate(ctx)
+	if IsInvalidToken(err) {
+		return c.login(ctx)
+	}
+	return auth.Token, err
+}
+
+// login registers again with the credentials of the last RegisterClient.
+func (c *Client) login(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	username, password := c.username, c.password
+	c.mu.Unlock()
+
+	auth, err := c.RegisterClient(ctx, username, password)
+	return auth.Token, err
+}
+
+// envelope is a reply as sent by the API. Status and ErrMsg are decoded
+// leniently, as the cloud sometimes sends a Status as a string or an ErrMsg
+// of the wrong type.
+type envelope struct {
+	Status json.RawMessage `json:"Status"`
+	ErrMsg json.RawMessage `json:"ErrMsg"`
+	Data   json.RawMessage `json:"Data"`
+	// Error is the message of a request refused outright, and Fields the
+	// params it was refused for.
+	Error  string       `json:"error"`
+	Fields []FieldError `json:"fields"`
+}
+
+// do sends req and decodes the Data of a successful reply into data.
+func (c *Client) do(ctx context.Context, req Request, data any) error {
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/?req="+url.QueryEscape(string(payload)), nil)
+	if err != nil {
+		return err
+	}
+	if c.secret != nil {
+		httpReq.Header.Set("X-Signature", sign(c.secret, payload))
+	}
+	res, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	reply := envelope{}
+	if err := json.Unmarshal(body, &reply); err != nil {
+		if res.StatusCode != http.StatusOK {
+			return &APIError{StatusCode: res.StatusCode, Message: http.StatusText(res.StatusCode)}
+		}
+		return fmt.Errorf("inimcloud: %s: invalid reply: %w", req.Method, err)
+	}
+
+	status, ok := parseStatus(reply.Status)
+	switch {
+	case ok && status != 0:
+		message := ""
+		json.Unmarshal(reply.ErrMsg, &message)
+		return &APIError{StatusCode: res.StatusCode, Status: status, Message: message}
+	case res.StatusCode == http.StatusBadRequest && len(reply.Fields) > 0:
+		return &ValidationError{Method: req.Method, Fields: reply.Fields}
+	case res.StatusCode != http.StatusOK:
+		message := reply.Error
+		if message == "" {
+			message = http.StatusText(res.StatusCode)
+		}
+		return &APIError{StatusCode: res.StatusCode, Message: message}
+	case !ok:
+		return fmt.Errorf("inimcloud: %s: reply without a Status", req.Method)
+	}
+
+	if data == nil || reply.Data == nil {
+		return nil
+	}
+	if err := json.Unmarshal(reply.Data, data); err != nil {
+		return fmt.Errorf("inimcloud: %s: invalid Data: %w", req.Method, err)
+	}
+	return nil
+}
+
+// parseStatus reads a Status sent as a number or a numeric string.
+func parseStatus(raw json.RawMessage) (int, bool) {
+	status := 0
+	if err := json.Unmarshal(raw, &status); err == nil {
+		return status, true
+	}
+	text := ""
+	if err := json.Unmarshal(raw, &text); err != nil {
+		return 0, false
+	}
+	status, err := strconv.Atoi(text)
+	return status, err == nil
+}
@@ -0,0 +1,118 @@
+package inimcloud
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stubServer answers each call with reply(method), counting the calls.
+type stubServer struct {
+	*httptest.Server
+	mu    sync.Mutex
+	calls map[Method]int
+}
+
+func newStubServer(t *testing.T, reply func(req Request) string) *stubServer {
+	t.Helper()
+	s := &stubServer{calls: map[Method]int{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := Request{}
+		if err := json.Unmarshal([]byte(r.URL.Query().Get("req")), &req); err != nil {
+			t.Errorf("invalid request %q: %v", r.URL.RawQuery, err)
+		}
+		s.mu.Lock()
+		s.calls[req.Method]++
+		s.mu.Unlock()
+		w.Write([]byte(reply(req)))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *stubServer) count(method Method) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[method]
+}
+
+func loginReply(req Request) string {
+	if req.Method == MethodRegisterClient || req.Method == MethodAuthenticate {
+		return `{"Status":0,"Data":{"Token":"t","TTL":60}}`
+	}
+	return `{"Status":0,"Data":{"Devices":[]}}`
+}
+
+func TestClientRenewsHalfwayThroughTheTTL(t *testing.T) {
+	s := newStubServer(t, loginReply)
+	c := NewClient(s.URL)
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	if _, err := c.RegisterClient(ctx, "user", "pass"); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(29 * time.Second)
+	if _, err := c.GetDevicesExtended(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := s.count(MethodAuthenticate); n != 0 {
+		t.Errorf("renewed %d times before half the TTL", n)
+	}
+	now = now.Add(time.Second)
+	if _, err := c.GetDevicesExtended(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := s.count(MethodAuthenticate); n != 1 {
+		t.Errorf("renewed %d times at half the TTL, want 1", n)
+	}
+}
+
+func TestClientToleratesRoughReplies(t *testing.T) {
+	for _, reply := range []string{
+		`{"Status":"0","Data":{"Devices":[{"DeviceId":7}]}}`,
+		`{"Status":0,"ErrMsg":0,"Data":{"Devices":[{"DeviceId":7}]}}`,
+		`{"Status":0,"Unexpected":{"Note":"x"},"Data":{"Devices":[{"DeviceId":7}]}}`,
+	} {
+		s := newStubServer(t, func(req Request) string {
+			if req.Method == MethodRegisterClient {
+				return `{"Status":0,"Data":{"Token":"t","TTL":60}}`
+			}
+			return reply
+		})
+		c := NewClient(s.URL)
+		c.RegisterClient(context.Background(), "user", "pass")
+		devices, err := c.GetDevicesExtended(context.Background())
+		if err != nil || len(devices) != 1 || devices[0].DeviceId != 7 {
+			t.Errorf("reply %s: devices %+v, err %v", reply, devices, err)
+		}
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	tests := []struct {
+		reply string
+		want  APIError
+	}{
+		{`{"Status":4,"ErrMsg":"Device not found"}`, APIError{StatusCode: 200, Status: 4, Message: "Device not found"}},
+		{`{"Status":"4","ErrMsg":"Device not found"}`, APIError{StatusCode: 200, Status: 4, Message: "Device not found"}},
+	}
+	for _, tt := range tests {
+		s := newStubServer(t, func(Request) string { return tt.reply })
+		_, err := NewClient(s.URL).RegisterClient(context.Background(), "user", "pass")
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || *apiErr != tt.want {
+			t.Errorf("reply %s: err = %v, want %+v", tt.reply, err, tt.want)
+		}
+	}
+
+	if _, err := NewClient("http://unused").GetDevicesExtended(context.Background()); !errors.Is(err, ErrNotRegistered) {
+		t.Errorf("call before RegisterClient: err = %v", err)
+	}
+}
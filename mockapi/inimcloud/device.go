@@ -0,0 +1,140 @@
+// Package inimcloud is a client for the Inim Cloud API. Its types model the
+// API's requests and replies; the mock server in this module serves the same
+// types, so the wire format is defined once. Field names match the wire
+// format.
+package inimcloud
+
+import (
+	"slices"
+	"time"
+)
+
+type Scenario struct {
+	ScenarioId int    `json:"ScenarioId"`
+	Name       string `json:"Name"`
+	// Areas lists the areas the scenario arms; every area when empty.
+	Areas []int `json:"Areas,omitempty"`
+}
+
+// Area is a partition of the panel. The API spells areas "Ares".
+type Area struct {
+	AreaId int    `json:"AresId"`
+	Name   string `json:"Name"`
+}
+
+type Zone struct {
+	ZoneId int `json:"ZoneId"`
+	// Type is the panel's zone type code.
+	Type int    `json:"Type"`
+	Name string `json:"Name"`
+	// Areas lists the ids of the areas the zone belongs to.
+	Areas      []int `json:"Areas"`
+	Visibility bool  `json:"Visibility"`
+}
+
+// Output is a relay or other output the panel can drive.
+type Output struct {
+	OutputId int    `json:"OutputId"`
+	Name     string `json:"Name"`
+}
+
+type Device struct {
+	DeviceId  int        `json:"DeviceId"`
+	Name      string     `json:"Name"`
+	Scenarios []Scenario `json:"Scenarios"`
+	Areas     []Area     `json:"Ares"`
+	Zones     []Zone     `json:"Zones"`
+	Outputs   []Output   `json:"Outputs,omitempty"`
+	// Capabilities lists the methods the device accepts. A nil list means
+	// the device supports every method.
+	Capabilities []Method `json:"Capabilities"`
+}
+
+// DeviceView is a device as reported by GetDevicesExtended, with its live
+// state.
+type DeviceView struct {
+	Device
+	ActiveScenario     int            `json:"ActiveScenario"`
+	ActiveScenarioName string         `json:"ActiveScenarioName"`
+	Version            int            `json:"Version"`
+	Firmware           FirmwareStatus `json:"Firmware"`
+	// DeviceTime is the panel's clock, only reported with IncludeTime.
+	DeviceTime string `json:"DeviceTime,omitempty"`
+	// Areas and Zones shadow those of Device with their live state.
+	Areas []AreaView `json:"Ares"`
+	Zones []ZoneView `json:"Zones"`
+	// Alarm reports whether any area is in alarm.
+	Alarm bool `json:"Alarm"`
+	// ExitDelay is the arming countdown in progress, if any.
+	ExitDelay *ExitDelayView `json:"ExitDelay,omitempty"`
+}
+
+// AreaView is an area with its live state.
+type AreaView struct {
+	Area
+	Armed bool `json:"Armed"`
+	Alarm bool `json:"Alarm"`
+}
+
+// Zone statuses.
+const (
+	ZoneClosed = 0
+	ZoneOpen   = 1
+)
+
+// ZoneView is a zone with its live state.
+type ZoneView struct {
+	Zone
+	Status int `json:"Status"`
+}
+
+type FirmwareStatus struct {
+	Version  string `json:"Version"`
+	Updating bool   `json:"Updating"`
+	Progress int    `json:"Progress"`
+}
+
+// ExitDelayView is an arming countdown as reported by GetDevicesExtended.
+type ExitDelayView struct {
+	ScenarioId int       `json:"ScenarioId"`
+	EndsAt     time.Time `json:"EndsAt"`
+}
+
+func (d Device) Area(areaId int) (Area, bool) {
+	for _, area := range d.Areas {
+		if area.AreaId == areaId {
+			return area, true
+		}
+	}
+	return Area{}, false
+}
+
+func (d Device) HasZone(zoneId int) bool {
+	for _, zone := range d.Zones {
+		if zone.ZoneId == zoneId {
+			return true
+		}
+	}
+	return false
+}
+
+func (d Device) Scenario(scenarioId int) (Scenario, bool) {
+	for _, scenario := range d.Scenarios {
+		if scenario.ScenarioId == scenarioId {
+			return scenario, true
+		}
+	}
+	return Scenario{}, false
+}
+
+// ScenarioName returns the name of one of the device's scenarios, or an
+// empty string if it has no such scenario.
+func (d Device) ScenarioName(scenarioId int) string {
+	scenario, _ := d.Scenario(scenarioId)
+	return scenario.Name
+}
+
+// Supports reports whether method may be called on the device.
+func (d Device) Supports(method Method) bool {
+	return d.Capabilities == nil || slices.Contains(d.Capabilities, method)
+}
@@ -0,0 +1,66 @@
+package inimcloud
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDeviceViewRoundTrip(t *testing.T) {
+	view := DeviceView{
+		Device: Device{
+			DeviceId:     545002,
+			Name:         "BLUEBERR 3",
+			Scenarios:    []Scenario{{ScenarioId: 0, Name: "ARM", Areas: []int{1}}},
+			Outputs:      []Output{{OutputId: 1, Name: "Siren"}},
+			Capabilities: []Method{MethodActivateScenario},
+		},
+		ActiveScenario:     0,
+		ActiveScenarioName: "ARM",
+		Version:            3,
+		Firmware:           FirmwareStatus{Version: "6.07", Progress: 100},
+		Areas:              []AreaView{{Area: Area{AreaId: 1, Name: "House"}, Armed: true, Alarm: true}},
+		Zones:              []ZoneView{{Zone: Zone{ZoneId: 1, Type: 1, Name: "Front door", Areas: []int{1}, Visibility: true}, Status: ZoneOpen}},
+		Alarm:              true,
+		ExitDelay:          &ExitDelayView{ScenarioId: 0, EndsAt: time.Date(2024, 1, 1, 0, 0, 30, 0, time.UTC)},
+	}
+	data, err := json.Marshal(view)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got DeviceView
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, view) {
+		t.Errorf("round trip = %+v, want %+v", got, view)
+	}
+}
+
+func TestDeviceViewOmitsUnsetFields(t *testing.T) {
+	data, err := json.Marshal(DeviceView{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"DeviceTime", "ExitDelay", "Outputs"} {
+		if _, ok := fields[name]; ok {
+			t.Errorf("%s present in %s", name, data)
+		}
+	}
+}
+
+func TestSupports(t *testing.T) {
+	all := Device{}
+	if !all.Supports(MethodSetDelays) {
+		t.Error("device without capabilities should support every method")
+	}
+	limited := Device{Capabilities: []Method{MethodActivateScenario}}
+	if !limited.Supports(MethodActivateScenario) || limited.Supports(MethodSetDelays) {
+		t.Errorf("Supports does not follow Capabilities %v", limited.Capabilities)
+	}
+}
@@ -0,0 +1,49 @@
+package inimcloud
+
+import (
+	"context"
+	"iter"
+)
+
+// DevicesIterator yields every device, fetching them pageSize at a time
+// with the Offset and Limit of GetDevicesExtended until the Total is
+// reached:
+//
+//	for device, err := range c.DevicesIterator(ctx, 50) {
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+//
+// A failed page, or ctx being done, is yielded as an error and ends the
+// iteration. Breaking out of the loop stops the paging.
+func (c *Client) DevicesIterator(ctx context.Context, pageSize int) iter.Seq2[DeviceView, error] {
+	return func(yield func(DeviceView, error) bool) {
+		offset := 0
+		for {
+			if err := ctx.Err(); err != nil {
+				yield(DeviceView{}, err)
+				return
+			}
+			page := struct {
+				Devices []DeviceView `json:"Devices"`
+				Total   int          `json:"Total"`
+			}{}
+			params := map[string]any{"Offset": offset, "Limit": pageSize}
+			if err := c.cachedCall(ctx, MethodGetDevicesExtended, params, &page); err != nil {
+				yield(DeviceView{}, err)
+				return
+			}
+			for _, device := range page.Devices {
+				if !yield(device, nil) {
+					return
+				}
+			}
+			offset += len(page.Devices)
+			if len(page.Devices) == 0 || offset >= page.Total {
+				return
+			}
+		}
+	}
+}
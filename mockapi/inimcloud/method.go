@@ -0,0 +1,27 @@
+package inimcloud
+
+// Method names an API method, as sent in a request's Method field.
+type Method string
+
+const (
+	MethodAuthenticate            Method = "Authenticate"
+	MethodRegisterClient          Method = "RegisterClient"
+	MethodGetDevicesExtended      Method = "GetDevicesExtended"
+	MethodActivateScenario        Method = "ActivateScenario"
+	MethodGetCommandStatus        Method = "GetCommandStatus"
+	MethodGetCapabilities         Method = "GetCapabilities"
+	MethodGetSystemTime           Method = "GetSystemTime"
+	MethodGetFaults               Method = "GetFaults"
+	MethodAckFault                Method = "AckFault"
+	MethodGetScenarioHistory      Method = "GetScenarioHistory"
+	MethodGetNotificationSettings Method = "GetNotificationSettings"
+	MethodSetNotificationSettings Method = "SetNotificationSettings"
+	MethodGetDelays               Method = "GetDelays"
+	MethodSetDelays               Method = "SetDelays"
+	MethodGetEventsLatest         Method = "GetEventsLatest"
+	MethodCancelActivation        Method = "CancelActivation"
+	MethodGetSessionInfo          Method = "GetSessionInfo"
+	MethodGetZoneConfig           Method = "GetZoneConfig"
+	MethodGetDeviceStatus         Method = "GetDeviceStatus"
+	MethodRequestPoll             Method = "RequestPoll"
+)
@@ -0,0 +1,17 @@
+package inimcloud
+
+// Request is an API call. It is sent JSON encoded in the "req" query
+// parameter of a GET on the API root.
+type Request struct {
+	Method   Method         `json:"Method"`
+	Token    string         `json:"Token"`
+	ClientId string         `json:"ClientId"`
+	Params   map[string]any `json:"Params"`
+}
+
+// AuthResponse is the Data of RegisterClient and Authenticate: the session
+// token and its lifetime in seconds.
+type AuthResponse struct {
+	Token string `json:"Token"`
+	TTL   int    `json:"TTL"`
+}
@@ -0,0 +1,20 @@
+package inimcloud
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// WithSigning signs every call with secret, sending the hex HMAC-SHA256 of
+// its "req" payload in the X-Signature header, as a mock started with
+// -signing-secret requires.
+func WithSigning(secret string) Option {
+	return func(c *Client) { c.secret = []byte(secret) }
+}
+
+func sign(secret, payload []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
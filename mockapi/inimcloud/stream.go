@@ -0,0 +1,50 @@
+package inimcloud
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"iter"
+	"net/http"
+)
+
+// StreamDevices reads the devices from the mock's /devices/stream, one at a
+// time as they arrive, so large accounts are never held in memory at once.
+// A failed request or a malformed line is yielded as an error and ends the
+// stream; breaking out of the loop, or ctx being done, closes it.
+func (c *Client) StreamDevices(ctx context.Context) iter.Seq2[DeviceView, error] {
+	return func(yield func(DeviceView, error) bool) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/devices/stream", nil)
+		if err != nil {
+			yield(DeviceView{}, err)
+			return
+		}
+		res, err := c.httpClient.Do(req)
+		if err != nil {
+			yield(DeviceView{}, err)
+			return
+		}
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			yield(DeviceView{}, &APIError{StatusCode: res.StatusCode, Message: http.StatusText(res.StatusCode)})
+			return
+		}
+
+		decoder := json.NewDecoder(res.Body)
+		for {
+			device := DeviceView{}
+			err := decoder.Decode(&device)
+			if errors.Is(err, io.EOF) {
+				return
+			}
+			if err != nil {
+				yield(DeviceView{}, err)
+				return
+			}
+			if !yield(device, nil) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package inimcloud
+
+import (
+	"net"
+	"net/http"
+	"time"
+)
+
+// defaultTransport is shared by every Client not given its own, so that
+// clients created in numbers reuse the same idle connections rather than
+// each dialing, and leaving behind, sockets of their own.
+var defaultTransport = NewTransport()
+
+// sharedClient sends the requests of Clients built without options.
+var sharedClient = &http.Client{Transport: defaultTransport}
+
+// NewTransport returns a transport with the pooling Clients use by default:
+// keep-alive connections, and enough of them kept idle per host for a busy
+// test suite to stop dialing once warmed up. Tune its fields and pass it to
+// WithTransport, possibly to several Clients.
+func NewTransport() *http.Transport {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	return &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          256,
+		MaxIdleConnsPerHost:   64,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+}
+
+// WithTransport sends requests through t. Clients given the same transport
+// share its connection pool.
+func WithTransport(t *http.Transport) Option {
+	return func(c *Client) { c.httpClient = &http.Client{Transport: t} }
+}
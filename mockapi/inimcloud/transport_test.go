@@ -0,0 +1,19 @@
+package inimcloud
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestClientsShareTheDefaultTransport(t *testing.T) {
+	a, b := NewClient("http://a"), NewClient("http://b")
+	if a.httpClient.Transport != http.RoundTripper(defaultTransport) || b.httpClient != a.httpClient {
+		t.Error("clients built without options do not share the default transport")
+	}
+
+	transport := NewTransport()
+	c, d := NewClient("http://c", WithTransport(transport)), NewClient("http://d", WithTransport(transport))
+	if c.httpClient.Transport != http.RoundTripper(transport) || d.httpClient.Transport != c.httpClient.Transport {
+		t.Error("WithTransport not used")
+	}
+}
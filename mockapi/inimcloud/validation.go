@@ -0,0 +1,27 @@
+package inimcloud
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FieldError describes one invalid param.
+type FieldError struct {
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
+}
+
+// ValidationError is a call refused for its params, with every invalid one
+// and why.
+type ValidationError struct {
+	Method Method
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	problems := make([]string, 0, len(e.Fields))
+	for _, field := range e.Fields {
+		problems = append(problems, field.Field+" "+field.Reason)
+	}
+	return fmt.Sprintf("inimcloud: %s: invalid params: %s", e.Method, strings.Join(problems, ", "))
+}
@@ -0,0 +1,50 @@
+package inimcloud
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestValidationErrorFields(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"Invalid params","fields":[` +
+			`{"field":"DeviceId","reason":"must be an integer"},` +
+			`{"field":"ScenarioId","reason":"is required"}]}`))
+	}))
+	defer s.Close()
+
+	_, err := NewClient(s.URL).RegisterClient(context.Background(), "user", "pass")
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("err = %v, want a ValidationError", err)
+	}
+	want := []FieldError{
+		{Field: "DeviceId", Reason: "must be an integer"},
+		{Field: "ScenarioId", Reason: "is required"},
+	}
+	if !slices.Equal(validationErr.Fields, want) {
+		t.Errorf("Fields = %+v, want %+v", validationErr.Fields, want)
+	}
+	if got := err.Error(); got != "inimcloud: RegisterClient: invalid params: DeviceId must be an integer, ScenarioId is required" {
+		t.Errorf("Error() = %q", got)
+	}
+}
+
+func TestBadRequestWithoutFieldsIsAnAPIError(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"Unknown method"}`))
+	}))
+	defer s.Close()
+
+	_, err := NewClient(s.URL).RegisterClient(context.Background(), "user", "pass")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest || apiErr.Message != "Unknown method" {
+		t.Errorf("err = %v, want a 400 APIError", err)
+	}
+}
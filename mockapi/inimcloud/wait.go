@@ -0,0 +1,50 @@
+package inimcloud
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const defaultPollInterval = time.Second
+
+// WithPollInterval sets how often WaitForScenario reads the device.
+func WithPollInterval(d time.Duration) Option {
+	return func(c *Client) { c.pollInterval = d }
+}
+
+// WaitForScenario returns once the device has scenarioId active and no
+// exit delay running, so an arming scenario only counts once its countdown
+// ran out. It reads the device past the cache, and gives up with the
+// context's error.
+func (c *Client) WaitForScenario(ctx context.Context, deviceId, scenarioId int) error {
+	ticker := time.NewTicker(c.pollInterval)
+	defer ticker.Stop()
+	for {
+		data := struct {
+			Devices []DeviceView `json:"Devices"`
+		}{}
+		if err := c.call(ctx, MethodGetDevicesExtended, nil, &data); err != nil {
+			return err
+		}
+		found := false
+		for _, device := range data.Devices {
+			if device.DeviceId != deviceId {
+				continue
+			}
+			if device.ActiveScenario == scenarioId && device.ExitDelay == nil {
+				return nil
+			}
+			found = true
+		}
+		if !found {
+			return fmt.Errorf("inimcloud: device %d not found", deviceId)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
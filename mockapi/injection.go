@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/lacherogwu/ha-inim_cloud/mockapi/inimcloud"
+)
+
+// Fault injection makes individual methods slow or unreliable at random,
+// for example:
+//
+//	{"Method": "GetDevicesExtended", "LatencyMs": 5000, "JitterMs": 1000, "ErrorRate": 0.3}
+//
+// delays every call by 5s give or take 1s and fails 30% of them with a 503.
+// A rule can also send a truncated JSON body at MalformedRate, or fail with
+// an API error (Status, by name) at StatusRate. Rules start from
+// -fault-rules, a JSON list of them, and are managed at runtime through
+// /admin/faults: GET lists them, POST adds or replaces a method's rule and
+// DELETE removes them all, or only the ?method= given. Keys may also be
+// spelled in snake_case, as in {"method": "GetDevicesExtended",
+// "latency_ms": 5000, "error_rate": 0.3}. Rules are decoded strictly: a
+// misspelled key such as latency is refused, not ignored.
+var faultRulesFile = flag.String("fault-rules", "", "JSON file listing per-method fault injection rules")
+
+type FaultRule struct {
+	Method        inimcloud.Method `json:"Method"`
+	LatencyMs     int              `json:"LatencyMs"`
+	JitterMs      int              `json:"JitterMs"`
+	ErrorRate     float64          `json:"ErrorRate"`
+	HttpStatus    int              `json:"HttpStatus,omitempty"`
+	MalformedRate float64          `json:"MalformedRate"`
+	StatusRate    float64          `json:"StatusRate"`
+	Status        string           `json:"Status,omitempty"`
+
+	code ErrorCode
+}
+
+// faultRuleKeys maps the snake_case spelling of each FaultRule key to the
+// key itself.
+var faultRuleKeys = map[string]string{
+	"method":         "Method",
+	"latency_ms":     "LatencyMs",
+	"jitter_ms":      "JitterMs",
+	"error_rate":     "ErrorRate",
+	"http_status":    "HttpStatus",
+	"malformed_rate": "MalformedRate",
+	"status_rate":    "StatusRate",
+	"status":         "Status",
+}
+
+// UnmarshalJSON decodes a rule spelled either way, refusing unknown keys.
+func (rule *FaultRule) UnmarshalJSON(data []byte) error {
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	renamed := make(map[string]json.RawMessage, len(fields))
+	for key, value := range fields {
+		if name, ok := faultRuleKeys[key]; ok {
+			key = name
+		}
+		renamed[key] = value
+	}
+	data, err := json.Marshal(renamed)
+	if err != nil {
+		return err
+	}
+
+	type plain FaultRule
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	return dec.Decode((*plain)(rule))
+}
+
+var (
+	faultRulesMu sync.Mutex
+	faultRules   = map[inimcloud.Method]FaultRule{}
+)
+
+// validate checks a rule and resolves its Status name.
+func (rule *FaultRule) validate() error {
+	if _, ok := handlers[rule.Method]; !ok {
+		return fmt.Errorf("unknown method %q", rule.Method)
+	}
+	if rule.LatencyMs < 0 || rule.JitterMs < 0 {
+		return fmt.Errorf("%s: negative latency", rule.Method)
+	}
+	for _, rate := range []float64{rule.ErrorRate, rule.MalformedRate, rule.StatusRate} {
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("%s: rates must be between 0 and 1", rule.Method)
+		}
+	}
+	if rule.HttpStatus == 0 {
+		rule.HttpStatus = http.StatusServiceUnavailable
+	}
+	if rule.HttpStatus < 500 || rule.HttpStatus > 599 {
+		return fmt.Errorf("%s: HttpStatus must be a 5xx", rule.Method)
+	}
+	if rule.StatusRate > 0 {
+		code, ok := errorNames[rule.Status]
+		if !ok {
+			return fmt.Errorf("%s: unknown error %q", rule.Method, rule.Status)
+		}
+		rule.code = code
+	}
+	return nil
+}
+
+func LoadFaultRules(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	rules := []FaultRule{}
+	if err := decodeFaultRules(bytes.NewReader(data), &rules); err != nil {
+		return err
+	}
+	for i := range rules {
+		if err := rules[i].validate(); err != nil {
+			return err
+		}
+	}
+
+	faultRulesMu.Lock()
+	defer faultRulesMu.Unlock()
+	for _, rule := range rules {
+		faultRules[rule.Method] = rule
+	}
+	return nil
+}
+
+// decodeFaultRules decodes one rule or a list of them.
+func decodeFaultRules(r io.Reader, v any) error {
+	return json.NewDecoder(r).Decode(v)
+}
+
+// InjectFault applies the method's rule, if any. It reports true when the
+// injected fault answered the call itself.
+func InjectFault(w http.ResponseWriter, method inimcloud.Method) bool {
+	faultRulesMu.Lock()
+	rule, ok := faultRules[method]
+	faultRulesMu.Unlock()
+	if !ok {
+		return false
+	}
+
+	latency := time.Duration(rule.LatencyMs) * time.Millisecond
+	if rule.JitterMs > 0 {
+		latency += time.Duration(randomN(2*rule.JitterMs+1)-rule.JitterMs) * time.Millisecond
+	}
+	clock.Sleep(max(latency, 0))
+
+	switch {
+	case chance(rule.ErrorRate):
+		WriteError(w, rule.HttpStatus, http.StatusText(rule.HttpStatus))
+		return true
+	case chance(rule.MalformedRate):
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, "{%q:0,%q:{\"Devi", *statusField, *dataField)
+		return true
+	case chance(rule.StatusRate):
+		WriteStatus(w, rule.code, rule.Status)
+		return true
+	}
+	return false
+}
+
+// SetFaultRules replaces every rule with rules, which must have been
+// validated.
+func SetFaultRules(rules []FaultRule) {
+	faultRulesMu.Lock()
+	defer faultRulesMu.Unlock()
+
+	faultRules = map[inimcloud.Method]FaultRule{}
+	for _, rule := range rules {
+		faultRules[rule.Method] = rule
+	}
+}
+
+func currentFaultRules() []FaultRule {
+	faultRulesMu.Lock()
+	defer faultRulesMu.Unlock()
+
+	rules := make([]FaultRule, 0, len(faultRules))
+	for _, rule := range faultRules {
+		rules = append(rules, rule)
+	}
+	slices.SortFunc(rules, func(a, b FaultRule) int {
+		return cmp.Compare(a.Method, b.Method)
+	})
+	return rules
+}
+
+func HandleFaultRules(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		rule := FaultRule{}
+		if err := decodeFaultRules(r.Body, &rule); err != nil {
+			WriteError(w, http.StatusBadRequest, "Invalid fault rule: "+err.Error())
+			return
+		}
+		if err := rule.validate(); err != nil {
+			WriteError(w, http.StatusBadRequest, "Invalid fault rule: "+err.Error())
+			return
+		}
+		faultRulesMu.Lock()
+		faultRules[rule.Method] = rule
+		faultRulesMu.Unlock()
+	case http.MethodDelete:
+		faultRulesMu.Lock()
+		if method := r.URL.Query().Get("method"); method != "" {
+			delete(faultRules, inimcloud.Method(method))
+		} else {
+			faultRules = map[inimcloud.Method]FaultRule{}
+		}
+		faultRulesMu.Unlock()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(currentFaultRules()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
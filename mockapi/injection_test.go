@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lacherogwu/ha-inim_cloud/mockapi/inimcloud"
+)
+
+// rawCall calls method and returns the recorded reply without decoding it.
+func rawCall(h http.Handler, method inimcloud.Method) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, `/?req={"Method":"`+string(method)+`"}`, nil))
+	return rec
+}
+
+func TestInjectedLatency(t *testing.T) {
+	c := useFakeClock(t)
+	h := newTestMux(t)
+	if rec := post(t, h, "/admin/faults", `{"Method": "GetDevicesExtended", "LatencyMs": 5000}`); rec.Code != http.StatusOK {
+		t.Fatalf("POST /admin/faults = %d %s", rec.Code, rec.Body)
+	}
+
+	getDevice(t, h)
+	if slept := c.Slept(); slept != 5*time.Second {
+		t.Errorf("GetDevicesExtended slept %s, want 5s", slept)
+	}
+	mustCall(t, h, inimcloud.MethodGetSystemTime, nil, nil)
+	if slept := c.Slept(); slept != 0 {
+		t.Errorf("GetSystemTime slept %s without a rule", slept)
+	}
+}
+
+func TestInjectedJitterStaysInRange(t *testing.T) {
+	c := useFakeClock(t)
+	h := newTestMux(t)
+	post(t, h, "/admin/faults", `{"Method": "GetSystemTime", "LatencyMs": 100, "JitterMs": 50}`)
+	for range 50 {
+		mustCall(t, h, inimcloud.MethodGetSystemTime, nil, nil)
+		if slept := c.Slept(); slept < 50*time.Millisecond || slept > 150*time.Millisecond {
+			t.Fatalf("slept %s, want 100ms give or take 50ms", slept)
+		}
+	}
+}
+
+func TestInjectedFailures(t *testing.T) {
+	h := newTestMux(t)
+	post(t, h, "/admin/faults", `{"Method": "GetSystemTime", "ErrorRate": 1, "HttpStatus": 502}`)
+	if rec := rawCall(h, inimcloud.MethodGetSystemTime); rec.Code != http.StatusBadGateway {
+		t.Errorf("ErrorRate 1 = %d %s, want 502", rec.Code, rec.Body)
+	}
+
+	post(t, h, "/admin/faults", `{"Method": "GetSystemTime", "MalformedRate": 1}`)
+	if rec := rawCall(h, inimcloud.MethodGetSystemTime); rec.Code != http.StatusOK || json.Valid(rec.Body.Bytes()) {
+		t.Errorf("MalformedRate 1 = %d %s, want a truncated 200", rec.Code, rec.Body)
+	}
+
+	post(t, h, "/admin/faults", `{"Method": "GetSystemTime", "StatusRate": 1, "Status": "ErrMaintenance"}`)
+	if status := callStatus(t, h, inimcloud.MethodGetSystemTime, nil); status != int(ErrMaintenance) {
+		t.Errorf("StatusRate 1: Status = %d, want %d", status, ErrMaintenance)
+	}
+
+	post(t, h, "/admin/faults", `{"Method": "GetDevicesExtended", "ErrorRate": 1}`)
+	req := httptest.NewRequest(http.MethodDelete, "/admin/faults?method=GetSystemTime", nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	rules := []FaultRule{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &rules); err != nil || len(rules) != 1 || rules[0].Method != inimcloud.MethodGetDevicesExtended {
+		t.Errorf("rules after deleting GetSystemTime's = %s", rec.Body)
+	}
+	mustCall(t, h, inimcloud.MethodGetSystemTime, nil, nil)
+}
+
+func TestFaultRulesAcceptSnakeCase(t *testing.T) {
+	h := newTestMux(t)
+	body := `{"method":"GetDevicesExtended","latency_ms":5000,"error_rate":0.3}`
+	if rec := post(t, h, "/admin/faults", body); rec.Code != http.StatusOK {
+		t.Fatalf("POST /admin/faults %s = %d %s", body, rec.Code, rec.Body)
+	}
+	rules := currentFaultRules()
+	if len(rules) != 1 || rules[0].Method != inimcloud.MethodGetDevicesExtended || rules[0].LatencyMs != 5000 || rules[0].ErrorRate != 0.3 {
+		t.Errorf("rules = %+v", rules)
+	}
+}
+
+func TestFaultRulesAreDecodedStrictly(t *testing.T) {
+	h := newTestMux(t)
+	for _, body := range []string{
+		`{"Method": "GetDevicesExtended", "latency": 5000}`,
+		`{"Method": "GetDevicesExtended", "ErrorRate": 1.5}`,
+		`{"Method": "GetNothing"}`,
+		`{"Method": "GetDevicesExtended", "HttpStatus": 404}`,
+		`{"Method": "GetDevicesExtended", "StatusRate": 1, "Status": "ErrNope"}`,
+	} {
+		if rec := post(t, h, "/admin/faults", body); rec.Code != http.StatusBadRequest {
+			t.Errorf("POST /admin/faults %s = %d, want 400", body, rec.Code)
+		}
+	}
+	if rules := currentFaultRules(); len(rules) != 0 {
+		t.Errorf("refused rules were added: %+v", rules)
+	}
+}
+
+func TestLoadFaultRules(t *testing.T) {
+	resetState()
+	dir := t.TempDir()
+	write := func(content string) string {
+		path := filepath.Join(dir, "faults.json")
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	if err := LoadFaultRules(write(`[{"Method": "GetSystemTime", "Latency": 10}]`)); err == nil || !strings.Contains(err.Error(), "Latency") {
+		t.Errorf("misspelled key: err = %v", err)
+	}
+	if err := LoadFaultRules(write(`[{"Method": "GetSystemTime", "latency_ms": 10}]`)); err != nil {
+		t.Fatal(err)
+	}
+	if rules := currentFaultRules(); len(rules) != 1 || rules[0].LatencyMs != 10 {
+		t.Errorf("loaded rules = %+v", rules)
+	}
+}
@@ -1,99 +1,234 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
+	"log"
+	"net"
 	"net/http"
-	"strconv"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/lacherogwu/ha-inim_cloud/mockapi/inimcloud"
 )
 
-type Method string
+// stateMu guards the store and everything derived from it. Each
+// activation is validated, applied, versioned and numbered in one critical
+// section, so concurrent activations of a device are applied one at a time
+// and in the order of their sequence numbers.
+var stateMu sync.Mutex
+
+var store = NewMemoryStore(defaultDevices, map[int]int{
+	545002: 1,
+})
 
-const (
-	MethodAuthenticate       Method = "Authenticate"
-	MethodRegisterClient     Method = "RegisterClient"
-	MethodGetDevicesExtended Method = "GetDevicesExtended"
-	MethodActivateScenario   Method = "ActivateScenario"
+// Envelope field names, configurable to check client parsers against
+// endpoints that use a different casing or naming.
+var (
+	statusField = flag.String("status-field", "Status", "name of the response envelope status field")
+	dataField   = flag.String("data-field", "Data", "name of the response envelope data field")
 )
 
-type ReqData struct {
-	Method Method         `json:"Method"`
-	Params map[string]any `json:"Params"`
-}
+func main() {
+	flag.Parse()
+	if !ValidSessionPolicy(*sessionPolicy) {
+		log.Fatalf("Invalid -session-policy %q: want allow-multiple, replace or reject", *sessionPolicy)
+	}
+	if *configFile != "" {
+		devices, active, err := LoadFixtures(*configFile)
+		if err != nil {
+			log.Fatalf("Failed to load fixtures: %v", err)
+		}
+		store = NewMemoryStore(devices, active)
+	}
+	maintenance.Store(*readOnly)
+	outage := ParseMethods(*outageMethods)
+	if unknown := UnknownMethods(outage); len(unknown) > 0 {
+		log.Fatalf("Invalid -outage-methods: unknown methods %v", unknown)
+	}
+	SetOutage(outage)
+	public := ParseMethods(*publicMethodList)
+	if unknown := UnknownMethods(public); len(unknown) > 0 {
+		log.Fatalf("Invalid -public-methods: unknown methods %v", unknown)
+	}
+	SetPublicMethods(public)
 
-var activeScenario = map[int]int{
-	545002: 1,
+	if *transitionPolicyFile != "" {
+		if err := LoadTransitionPolicy(*transitionPolicyFile); err != nil {
+			log.Fatalf("Failed to load transition policy: %v", err)
+		}
+	}
+
+	if *credentialsFile != "" {
+		if err := LoadCredentials(*credentialsFile); err != nil {
+			log.Fatalf("Failed to load credentials: %v", err)
+		}
+	}
+
+	if *statusMapFile != "" {
+		if err := LoadStatusMap(*statusMapFile); err != nil {
+			log.Fatalf("Failed to load status map: %v", err)
+		}
+	}
+
+	if *faultRulesFile != "" {
+		if err := LoadFaultRules(*faultRulesFile); err != nil {
+			log.Fatalf("Failed to load fault rules: %v", err)
+		}
+	}
+
+	if *behaviorFile != "" {
+		if err := LoadBehavior(*behaviorFile); err != nil {
+			log.Fatalf("Failed to load behavior script: %v", err)
+		}
+	}
+
+	mux := NewMux()
+
+	listener, err := net.Listen("tcp", ":8080")
+	if err != nil {
+		log.Fatalf("Failed to listen: %v", err)
+	}
+	if *maxConns > 0 {
+		listener = LimitListener(listener, *maxConns)
+	}
+
+	server := &http.Server{
+		Handler:      CountRequests(mux),
+		ReadTimeout:  *readTimeout,
+		WriteTimeout: *writeTimeout,
+		IdleTimeout:  *idleTimeout,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	done := make(chan struct{})
+	go func() {
+		<-ctx.Done()
+		// A second signal stops the server without draining.
+		stop()
+		Shutdown(server)
+		close(done)
+	}()
+
+	ready.Store(true)
+	fmt.Println("Server is running on http://localhost:8080")
+	if err := server.Serve(listener); err != http.ErrServerClosed {
+		log.Fatalf("Server failed: %v", err)
+	}
+	<-done
 }
 
-func main() {
+// NewMux routes the API and the admin endpoints. The middlewares read their
+// flags when the mux is built.
+func NewMux() *http.ServeMux {
 	mux := http.NewServeMux()
+	mux.HandleFunc("/{$}", Warmup(Degrade(HandleApi)))
+	mux.HandleFunc("POST /rpc", Warmup(Degrade(HandleRpc)))
+	mux.HandleFunc("GET /devices/stream", Warmup(HandleDeviceStream))
+	mux.HandleFunc("GET /export/ha.yaml", HandleExportHa)
+	mux.HandleFunc("GET /readyz", HandleReadyz)
+	mux.HandleFunc("GET /admin/snapshot", HandleSnapshot)
+	mux.HandleFunc("POST /admin/restore", HandleRestore)
+	mux.HandleFunc("POST /admin/maintenance", HandleMaintenance)
+	mux.HandleFunc("GET /admin/outage", HandleOutage)
+	mux.HandleFunc("POST /admin/outage", HandleOutage)
+	mux.HandleFunc("GET /admin/faults", HandleFaultRules)
+	mux.HandleFunc("POST /admin/faults", HandleFaultRules)
+	mux.HandleFunc("DELETE /admin/faults", HandleFaultRules)
+	mux.HandleFunc("POST /admin/devices/{id}/faults", HandleInjectFault)
+	mux.HandleFunc("POST /admin/devices/{id}/firmware-update", HandleFirmwareUpdate)
+	mux.HandleFunc("POST /admin/devices/{id}/zones/{zone}/open", HandleOpenZone)
+	mux.HandleFunc("POST /admin/devices/{id}/zones/{zone}/close", HandleCloseZone)
+	mux.HandleFunc("POST /admin/devices/{id}/alarm", HandleAlarm)
+	mux.HandleFunc("POST /admin/devices/{id}/scenario", HandleSetScenario)
+	mux.HandleFunc("/", HandleNotFound)
+	return mux
+}
 
-	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+// HandleApi serves the cloud API, which is always called on the root path
+// with the JSON request encoded in the "req" query parameter.
+func HandleApi(w http.ResponseWriter, r *http.Request) {
+	reqJson := r.URL.Query().Get("req")
+	if !ValidSignature([]byte(reqJson), r.Header.Get("X-Signature")) {
+		WriteError(w, http.StatusUnauthorized, "Invalid signature")
+		return
+	}
 
-		reqJson := r.URL.Query().Get("req")
+	reqData := &inimcloud.Request{}
+	err := json.Unmarshal([]byte(reqJson), reqData)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid JSON request")
+		return
+	}
+	if token, ok := BearerToken(r); ok {
+		reqData.Token = token
+	}
+	TrackSession(reqData.Token, r)
 
-		reqData := &ReqData{}
-		err := json.Unmarshal([]byte(reqJson), reqData)
-		if err != nil {
-			WriteError(w, http.StatusBadRequest, "Invalid JSON request")
+	fmt.Printf("Received request with method: %s\n", reqData.Method)
+
+	Dispatch(w, reqData)
+}
+
+// Dispatch runs the handler for reqData.Method, shared by every transport.
+func Dispatch(w http.ResponseWriter, reqData *inimcloud.Request) {
+	method, ok := LookupMethod(reqData.Method)
+	if !ok {
+		WriteError(w, http.StatusBadRequest, "Unknown method")
+		return
+	}
+	reqData.Method = method
+	handler := handlers[method]
+	if ApplyBehavior(w, reqData.Method) {
+		return
+	}
+	if InjectFault(w, reqData.Method) {
+		return
+	}
+	if InOutage(reqData.Method) {
+		WriteError(w, http.StatusServiceUnavailable, "Service unavailable")
+		return
+	}
+	if *requireToken && !publicMethods[reqData.Method] && !ValidToken(reqData.Token) {
+		WriteStatus(w, ErrInvalidToken, "Token not valid or expired")
+		return
+	}
+	if writeMethods[reqData.Method] && maintenance.Load() {
+		WriteStatus(w, ErrMaintenance, "Maintenance in progress")
+		return
+	}
+	if deviceId, ok := paramInt(reqData.Params, "DeviceId"); ok {
+		account := RequestAccount(reqData)
+		stateMu.Lock()
+		device, found := store.Device(deviceId)
+		updating := found && Firmware(device, clock.Now()).Updating
+		stateMu.Unlock()
+		if found && !device.VisibleTo(account) {
+			WriteStatus(w, ErrUnknownDevice, "Device not found")
 			return
 		}
-
-		fmt.Printf("Received request with method: %s\n", reqData.Method)
-
-		switch reqData.Method {
-		case MethodAuthenticate:
-			WriteJson(w, map[string]any{
-				"Token": "e255f93b-467c-4248-9315-879fa727d82d",
-				"TTL":   3600,
-			})
-		case MethodRegisterClient:
-			WriteJson(w, map[string]any{
-				"Token": "e255f93b-467c-4248-9315-879fa727d82d",
-				"TTL":   3600,
-			})
-
-		case MethodGetDevicesExtended:
-			WriteJson(w, map[string]any{
-				"Devices": []map[string]any{
-					{
-						"DeviceId":       545002,
-						"ActiveScenario": activeScenario[545002],
-						"Name":           "BLUEBERR 3",
-						"Scenarios": []map[string]any{
-							{
-								"ScenarioId": 0,
-								"Name":       "ARM",
-							},
-							{
-								"ScenarioId": 1,
-								"Name":       "DISARM",
-							},
-							{
-								"ScenarioId": 2,
-								"Name":       "STAY",
-							},
-						},
-					},
-				},
-			})
-		case MethodActivateScenario:
-			scenarioIdStr := reqData.Params["ScenarioId"].(string)
-			deviceIdStr := reqData.Params["DeviceId"].(string)
-
-			scenarioId, _ := strconv.Atoi(scenarioIdStr)
-			deviceId, _ := strconv.Atoi(deviceIdStr)
-
-			activeScenario[int(deviceId)] = int(scenarioId)
-			WriteJson(w, map[string]any{})
-
-		default:
-			WriteError(w, http.StatusBadRequest, "Unknown method")
+		if found && deviceActions[reqData.Method] && !device.Supports(reqData.Method) {
+			WriteStatus(w, ErrNotSupported, "Method not supported by device")
+			return
 		}
-	})
+		if updating && writeMethods[reqData.Method] {
+			WriteStatus(w, ErrDeviceUpdating, "Firmware update in progress")
+			return
+		}
+	}
+	handler(w, reqData)
+}
 
-	fmt.Println("Server is running on http://localhost:8080")
-	http.ListenAndServe(":8080", mux)
+// HandleNotFound answers any path without a registered route, so stray
+// requests are not mistaken for malformed API calls.
+func HandleNotFound(w http.ResponseWriter, r *http.Request) {
+	WriteError(w, http.StatusNotFound, "Not found")
 }
 
 func WriteJson(w http.ResponseWriter, data any) {
@@ -101,15 +236,28 @@ func WriteJson(w http.ResponseWriter, data any) {
 	w.WriteHeader(http.StatusOK)
 
 	resData := map[string]any{
-		"Status": 0,
-		"Data":   data,
+		*statusField: 0,
+		*dataField:   data,
 	}
+	Roughen(resData)
 
 	if err := json.NewEncoder(w).Encode(resData); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
 }
 
+// WriteValidationError rejects a request whose params are invalid, with the
+// field path and reason for each.
+func WriteValidationError(w http.ResponseWriter, fields []inimcloud.FieldError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+
+	errorResponse := map[string]any{"error": "Invalid params", "fields": fields}
+	if err := json.NewEncoder(w).Encode(errorResponse); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
 func WriteError(w http.ResponseWriter, status int, message string) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
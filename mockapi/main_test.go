@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/lacherogwu/ha-inim_cloud/mockapi/inimcloud"
+)
+
+const testDevice = 545002
+
+// newTestMux resets the mock to its built-in state and returns the routes
+// serving it. Flags read by the middlewares must be set beforehand.
+func newTestMux(t *testing.T) http.Handler {
+	t.Helper()
+	resetState()
+	return NewMux()
+}
+
+func resetState() {
+	fresh := NewMemoryStore(defaultDevices, map[int]int{testDevice: 1})
+	stateMu.Lock()
+	sessionsMu.Lock()
+	store = fresh
+	sessionsMu.Unlock()
+	transitionPolicy = map[int]map[int][]int{}
+	stateMu.Unlock()
+	RestoreSnapshot(Snapshot{StoreState: fresh.Snapshot()})
+
+	credentialValidator = acceptAllCredentials{}
+	statusMap = map[ErrorCode]int{}
+	degradation = &degrader{}
+}
+
+// setFlag overrides a flag for the duration of the test.
+func setFlag[T any](t *testing.T, flag *T, value T) {
+	t.Helper()
+	old := *flag
+	*flag = value
+	t.Cleanup(func() { *flag = old })
+}
+
+// apiReply is the envelope of an API reply, or the body of a request
+// refused outright.
+type apiReply struct {
+	Status int             `json:"Status"`
+	ErrMsg string          `json:"ErrMsg"`
+	Data   json.RawMessage `json:"Data"`
+	Error  string          `json:"error"`
+}
+
+// callApi sends req the way the integration does, in the req query
+// parameter, and decodes the reply.
+func callApi(t *testing.T, h http.Handler, req inimcloud.Request) (*httptest.ResponseRecorder, apiReply) {
+	t.Helper()
+	body, err := json.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?req="+url.QueryEscape(string(body)), nil))
+
+	reply := apiReply{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &reply); err != nil {
+		t.Fatalf("%s: invalid reply %q: %v", req.Method, rec.Body, err)
+	}
+	return rec, reply
+}
+
+// mustCall calls method, fails the test unless it succeeds, and decodes
+// the reply's Data into data, if not nil.
+func mustCall(t *testing.T, h http.Handler, method inimcloud.Method, params map[string]any, data any) {
+	t.Helper()
+	rec, reply := callApi(t, h, inimcloud.Request{Method: method, Params: params})
+	if rec.Code != http.StatusOK || reply.Status != 0 {
+		t.Fatalf("%s(%v) = %d %s", method, params, rec.Code, rec.Body)
+	}
+	if data != nil {
+		if err := json.Unmarshal(reply.Data, data); err != nil {
+			t.Fatalf("%s: invalid Data %s: %v", method, reply.Data, err)
+		}
+	}
+}
+
+// callStatus calls method and returns the envelope Status of the reply.
+func callStatus(t *testing.T, h http.Handler, method inimcloud.Method, params map[string]any) int {
+	t.Helper()
+	_, reply := callApi(t, h, inimcloud.Request{Method: method, Params: params})
+	return reply.Status
+}
+
+// getDevice reads the test device through GetDevicesExtended.
+func getDevice(t *testing.T, h http.Handler) inimcloud.DeviceView {
+	t.Helper()
+	data := struct{ Devices []inimcloud.DeviceView }{}
+	mustCall(t, h, inimcloud.MethodGetDevicesExtended, nil, &data)
+	for _, device := range data.Devices {
+		if device.DeviceId == testDevice {
+			return device
+		}
+	}
+	t.Fatalf("device %d missing from %+v", testDevice, data.Devices)
+	return inimcloud.DeviceView{}
+}
+
+func activate(scenarioId int) map[string]any {
+	return map[string]any{"DeviceId": testDevice, "ScenarioId": scenarioId}
+}
+
+// post sends an admin request with a JSON body.
+func post(t *testing.T, h http.Handler, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+	return rec
+}
+
+func TestUnknownPathIsJson404(t *testing.T) {
+	h := newTestMux(t)
+	for _, path := range []string{"/nope", "/admin/nope", "/api/v1/devices"} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("GET %s = %d, want 404", path, rec.Code)
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("GET %s Content-Type = %q", path, ct)
+		}
+		body := map[string]string{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body["error"] != "Not found" {
+			t.Errorf("GET %s body = %s", path, rec.Body)
+		}
+	}
+}
+
+func TestRootStillServesApi(t *testing.T) {
+	h := newTestMux(t)
+	rec, reply := callApi(t, h, inimcloud.Request{Method: inimcloud.MethodGetSystemTime})
+	if rec.Code != http.StatusOK || reply.Status != 0 {
+		t.Errorf("GetSystemTime = %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestEnvelopeFieldNames(t *testing.T) {
+	setFlag(t, statusField, "status")
+	setFlag(t, dataField, "result")
+	h := newTestMux(t)
+
+	rec, _ := callApi(t, h, inimcloud.Request{Method: inimcloud.MethodGetSystemTime})
+	body := map[string]json.RawMessage{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if string(body["status"]) != "0" || body["result"] == nil {
+		t.Errorf("success envelope = %s", rec.Body)
+	}
+	if _, ok := body["Status"]; ok {
+		t.Errorf("default Status field still present in %s", rec.Body)
+	}
+
+	rec, _ = callApi(t, h, inimcloud.Request{Method: inimcloud.MethodActivateScenario, Params: activate(9)})
+	body = map[string]json.RawMessage{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if string(body["status"]) != "5" {
+		t.Errorf("error envelope = %s, want status 5", rec.Body)
+	}
+}
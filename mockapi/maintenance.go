@@ -0,0 +1,44 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/lacherogwu/ha-inim_cloud/mockapi/inimcloud"
+)
+
+// While in maintenance the cloud keeps answering reads but refuses writes.
+// The mode starts from -read-only and can be flipped at runtime through
+// POST /admin/maintenance.
+var readOnly = flag.Bool("read-only", false, "start in maintenance mode, refusing write methods")
+
+var maintenance atomic.Bool
+
+// writeMethods are the methods refused during maintenance.
+var writeMethods = map[inimcloud.Method]bool{
+	inimcloud.MethodActivateScenario:        true,
+	inimcloud.MethodAckFault:                true,
+	inimcloud.MethodSetNotificationSettings: true,
+	inimcloud.MethodSetDelays:               true,
+	inimcloud.MethodCancelActivation:        true,
+}
+
+type MaintenanceState struct {
+	ReadOnly bool `json:"ReadOnly"`
+}
+
+func HandleMaintenance(w http.ResponseWriter, r *http.Request) {
+	state := MaintenanceState{}
+	if err := json.NewDecoder(r.Body).Decode(&state); err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid maintenance state")
+		return
+	}
+
+	maintenance.Store(state.ReadOnly)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(state); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/lacherogwu/ha-inim_cloud/mockapi/inimcloud"
+)
+
+func TestMaintenanceRefusesWrites(t *testing.T) {
+	h := newTestMux(t)
+	if rec := post(t, h, "/admin/maintenance", `{"ReadOnly": true}`); rec.Code != http.StatusOK {
+		t.Fatalf("POST /admin/maintenance = %d %s", rec.Code, rec.Body)
+	}
+
+	for method, params := range map[inimcloud.Method]map[string]any{
+		inimcloud.MethodActivateScenario: activate(2),
+		inimcloud.MethodSetDelays:        {"DeviceId": testDevice, "ExitDelay": 10},
+	} {
+		if status := callStatus(t, h, method, params); status != int(ErrMaintenance) {
+			t.Errorf("%s in maintenance = %d, want %d", method, status, ErrMaintenance)
+		}
+	}
+	if got := getDevice(t, h).ActiveScenario; got != 1 {
+		t.Errorf("scenario changed in maintenance: %d", got)
+	}
+
+	post(t, h, "/admin/maintenance", `{"ReadOnly": false}`)
+	mustCall(t, h, inimcloud.MethodActivateScenario, activate(2), nil)
+}
+
+func TestMaintenanceAllowsReads(t *testing.T) {
+	h := newTestMux(t)
+	maintenance.Store(true)
+	getDevice(t, h)
+	caps := struct{ Features map[string]bool }{}
+	mustCall(t, h, inimcloud.MethodGetCapabilities, nil, &caps)
+	if !caps.Features["Maintenance"] {
+		t.Error("GetCapabilities doesn't report maintenance")
+	}
+}
+
+func TestMaintenanceInvalidState(t *testing.T) {
+	h := newTestMux(t)
+	if rec := post(t, h, "/admin/maintenance", `{"ReadOnly": "yes"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid maintenance state = %d, want 400", rec.Code)
+	}
+}
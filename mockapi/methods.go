@@ -0,0 +1,314 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lacherogwu/ha-inim_cloud/mockapi/inimcloud"
+)
+
+// ApiVersion is reported by GetCapabilities.
+const ApiVersion = "1"
+
+// MethodHandler serves one API method.
+type MethodHandler func(w http.ResponseWriter, reqData *inimcloud.Request)
+
+// handlers maps every supported method to its handler. It is filled in init
+// because GetCapabilities reports the contents of the map itself.
+var handlers map[inimcloud.Method]MethodHandler
+
+func init() {
+	handlers = map[inimcloud.Method]MethodHandler{
+		inimcloud.MethodAuthenticate:            HandleAuthenticate,
+		inimcloud.MethodRegisterClient:          HandleRegisterClient,
+		inimcloud.MethodGetDevicesExtended:      HandleGetDevicesExtended,
+		inimcloud.MethodActivateScenario:        HandleActivateScenario,
+		inimcloud.MethodGetCommandStatus:        HandleGetCommandStatus,
+		inimcloud.MethodGetCapabilities:         HandleGetCapabilities,
+		inimcloud.MethodGetSystemTime:           HandleGetSystemTime,
+		inimcloud.MethodGetFaults:               HandleGetFaults,
+		inimcloud.MethodAckFault:                HandleAckFault,
+		inimcloud.MethodGetScenarioHistory:      HandleGetScenarioHistory,
+		inimcloud.MethodGetNotificationSettings: HandleGetNotificationSettings,
+		inimcloud.MethodSetNotificationSettings: HandleSetNotificationSettings,
+		inimcloud.MethodGetDelays:               HandleGetDelays,
+		inimcloud.MethodSetDelays:               HandleSetDelays,
+		inimcloud.MethodGetEventsLatest:         HandleGetEventsLatest,
+		inimcloud.MethodCancelActivation:        HandleCancelActivation,
+		inimcloud.MethodGetSessionInfo:          HandleGetSessionInfo,
+		inimcloud.MethodGetZoneConfig:           HandleGetZoneConfig,
+		inimcloud.MethodGetDeviceStatus:         HandleGetDeviceStatus,
+		inimcloud.MethodRequestPoll:             HandleRequestPoll,
+	}
+}
+
+// Some clients get the casing of methods wrong. The real cloud is case
+// sensitive, so matching case-insensitively is opt-in.
+var caseInsensitiveMethods = flag.Bool("case-insensitive-methods", false, "match method names regardless of case")
+
+// LookupMethod resolves a requested method name to a supported method.
+func LookupMethod(name inimcloud.Method) (inimcloud.Method, bool) {
+	if _, ok := handlers[name]; ok {
+		return name, true
+	}
+	if *caseInsensitiveMethods {
+		for method := range handlers {
+			if strings.EqualFold(string(method), string(name)) {
+				return method, true
+			}
+		}
+	}
+	return "", false
+}
+
+// ParseMethods splits a comma-separated list of methods, as given to
+// -outage-methods and -public-methods.
+func ParseMethods(list string) []inimcloud.Method {
+	methods := []inimcloud.Method{}
+	for _, name := range strings.Split(list, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			methods = append(methods, inimcloud.Method(name))
+		}
+	}
+	return methods
+}
+
+// UnknownMethods returns the methods the mock doesn't serve, in the order
+// given.
+func UnknownMethods(methods []inimcloud.Method) []inimcloud.Method {
+	unknown := []inimcloud.Method{}
+	for _, method := range methods {
+		if _, ok := handlers[method]; !ok {
+			unknown = append(unknown, method)
+		}
+	}
+	return unknown
+}
+
+// HandleAuthenticate renews the caller's session. A token that isn't live
+// is refused; the client has to register again.
+func HandleAuthenticate(w http.ResponseWriter, reqData *inimcloud.Request) {
+	session, ok := RenewSession(reqData.Token)
+	if !ok {
+		WriteStatus(w, ErrInvalidToken, "Token not valid or expired")
+		return
+	}
+	writeSession(w, session)
+}
+
+func HandleRegisterClient(w http.ResponseWriter, reqData *inimcloud.Request) {
+	if !CheckCredentials(w, reqData) {
+		return
+	}
+	clientId, _ := paramString(reqData.Params, "ClientId")
+	username, _ := paramString(reqData.Params, "Username")
+	session, ok := StartSession(clientId, username)
+	if !ok {
+		WriteStatus(w, ErrSessionActive, "Session already active for this client")
+		return
+	}
+	writeSession(w, session)
+}
+
+// padBytes inflates GetDevicesExtended replies with a synthetic Padding
+// field, to measure transfer and parsing cost apart from the device count.
+var padBytes = flag.Int("pad-bytes", 0, "pad GetDevicesExtended replies with this many bytes")
+
+func HandleGetDevicesExtended(w http.ResponseWriter, reqData *inimcloud.Request) {
+	// Offset and Limit page through the devices in the requested order. A
+	// paged reply also reports the Total number of devices to page through.
+	v := validateParams(reqData.Params)
+	offset, hasOffset := v.OptionalInt("Offset")
+	limit, hasLimit := v.OptionalInt("Limit")
+	if offset < 0 {
+		v.Reject("Offset", "must not be negative")
+	}
+	if hasLimit && limit < 1 {
+		v.Reject("Limit", "must be at least 1")
+	}
+	if !v.Check(w) {
+		return
+	}
+
+	account := RequestAccount(reqData)
+	stateMu.Lock()
+	defer stateMu.Unlock()
+
+	views := AccountViews(account)
+
+	orderBy, _ := paramString(reqData.Params, "OrderBy")
+	direction, _ := paramString(reqData.Params, "Order")
+	if err := SortDevices(views, orderBy, direction); err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid OrderBy: "+err.Error())
+		return
+	}
+	// ChangedSince turns the read into a delta: only devices changed after it
+	// are returned, along with the ServerTime to pass on the next call.
+	now := clock.Now()
+	var serverTime string
+	if since, ok := paramString(reqData.Params, "ChangedSince"); ok {
+		sinceTime, err := time.Parse(time.RFC3339Nano, since)
+		if err != nil {
+			WriteError(w, http.StatusBadRequest, "Invalid ChangedSince")
+			return
+		}
+		serverTime = now.Format(time.RFC3339Nano)
+		views = slices.DeleteFunc(views, func(view inimcloud.DeviceView) bool {
+			return !store.ChangedAt(view.DeviceId).After(sinceTime)
+		})
+	}
+
+	// IncludeTime reports the server's clock next to each panel's, so drift
+	// can be spotted without a GetSystemTime round trip.
+	if includeTime, _ := paramBool(reqData.Params, "IncludeTime"); includeTime {
+		serverTime = now.Format(time.RFC3339Nano)
+		deviceTime := now.Add(*clockSkew).Format(time.RFC3339)
+		for i := range views {
+			views[i].DeviceTime = deviceTime
+		}
+	}
+
+	data := map[string]any{}
+	if hasOffset || hasLimit {
+		data["Total"] = len(views)
+		data["Offset"] = offset
+		end := len(views)
+		if hasLimit {
+			data["Limit"] = limit
+			end = min(end, offset+limit)
+		}
+		views = views[min(offset, end):end]
+	}
+	data["Devices"] = views
+	if serverTime != "" {
+		data["ServerTime"] = serverTime
+	}
+	if fields, ok := paramStrings(reqData.Params, "Fields"); ok {
+		list := make([]map[string]any, 0, len(views))
+		for _, view := range views {
+			list = append(list, Project(view, fields))
+		}
+		data["Devices"] = list
+	}
+	if *padBytes > 0 {
+		data["Padding"] = strings.Repeat("x", *padBytes)
+	}
+
+	interval := NextPollInterval(RequestActor(reqData), offset == 0)
+	w.Header().Set("X-Poll-Interval", strconv.Itoa(int(interval.Seconds())))
+	WriteJson(w, data)
+}
+
+// Project returns the subset of v's JSON fields named in fields. Unknown
+// field names are ignored.
+func Project(v any, fields []string) map[string]any {
+	obj := map[string]any{}
+	raw, _ := json.Marshal(v)
+	json.Unmarshal(raw, &obj)
+
+	projected := make(map[string]any, len(fields))
+	for _, field := range fields {
+		if v, ok := obj[field]; ok {
+			projected[field] = v
+		}
+	}
+	return projected
+}
+
+func HandleActivateScenario(w http.ResponseWriter, reqData *inimcloud.Request) {
+	v := validateParams(reqData.Params)
+	scenarioId := v.Int("ScenarioId")
+	deviceId := v.Int("DeviceId")
+	dryRun := v.OptionalBool("DryRun")
+	ifVersion, checkVersion := v.OptionalInt("IfVersion")
+	if !v.Check(w) {
+		return
+	}
+
+	stateMu.Lock()
+	defer stateMu.Unlock()
+
+	if apiErr := ValidateActivation(deviceId, scenarioId); apiErr != nil {
+		WriteApiError(w, apiErr)
+		return
+	}
+	// IfVersion guards against overwriting a change the caller hasn't seen.
+	if checkVersion && ifVersion != store.Version(deviceId) {
+		WriteStatus(w, ErrVersionConflict, "Device was modified, reload and retry")
+		return
+	}
+
+	// A dry run stops once the activation is known to be acceptable.
+	if dryRun {
+		WriteJson(w, map[string]any{"DryRun": true})
+		return
+	}
+
+	store.SetLastCommandAt(deviceId, clock.Now())
+	if *asyncActivation {
+		WriteJson(w, QueueActivation(deviceId, scenarioId, RequestActor(reqData)))
+		return
+	}
+
+	device, _ := store.Device(deviceId)
+	data := map[string]any{}
+	if delay := Activate(device, scenarioId, RequestActor(reqData)); delay > 0 {
+		data["ExitDelay"] = int(delay.Seconds())
+	}
+	data["Version"] = store.Version(deviceId)
+	data["Sequence"] = NextSequence()
+	WriteJson(w, data)
+}
+
+func HandleGetCommandStatus(w http.ResponseWriter, reqData *inimcloud.Request) {
+	commandId, _ := paramString(reqData.Params, "CommandId")
+
+	cmd, ok := GetCommand(commandId)
+	if !ok {
+		WriteError(w, http.StatusBadRequest, "Unknown command")
+		return
+	}
+	WriteJson(w, cmd)
+}
+
+// HandleGetCapabilities lets clients discover what this server supports
+// instead of assuming it: the registered methods, the API version and which
+// optional behaviours are switched on.
+func HandleGetCapabilities(w http.ResponseWriter, reqData *inimcloud.Request) {
+	methods := make([]inimcloud.Method, 0, len(handlers))
+	for method := range handlers {
+		methods = append(methods, method)
+	}
+	slices.Sort(methods)
+
+	WriteJson(w, map[string]any{
+		"ApiVersion": ApiVersion,
+		"Methods":    methods,
+		"Features": map[string]bool{
+			"AsyncActivation":  *asyncActivation,
+			"EventualReads":    *propagationDelay > 0,
+			"TransitionPolicy": len(transitionPolicy) > 0,
+			"DegradedMode":     *degradeAfter > 0,
+			"Maintenance":      maintenance.Load(),
+			"ClockSkew":        *clockSkew != 0,
+			"TokenRequired":    *requireToken,
+			"ExitDelay":        *exitDelayArming,
+		},
+	})
+}
+
+// clockSkew offsets the panel time reported by GetSystemTime and by
+// GetDevicesExtended with IncludeTime, to simulate a panel whose clock has
+// drifted. Nothing else in the mock is affected.
+var clockSkew = flag.Duration("clock-skew", 0, "offset added to the time reported by GetSystemTime")
+
+func HandleGetSystemTime(w http.ResponseWriter, reqData *inimcloud.Request) {
+	WriteJson(w, map[string]any{
+		"SystemTime": clock.Now().Add(*clockSkew).Format(time.RFC3339),
+	})
+}
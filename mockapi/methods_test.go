@@ -0,0 +1,306 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/lacherogwu/ha-inim_cloud/mockapi/inimcloud"
+)
+
+func TestGetDevicesExtendedFields(t *testing.T) {
+	h := newTestMux(t)
+	data := struct{ Devices []map[string]json.RawMessage }{}
+	mustCall(t, h, inimcloud.MethodGetDevicesExtended, map[string]any{"Fields": []string{"DeviceId", "ActiveScenario", "Bogus"}}, &data)
+
+	if len(data.Devices) != 1 {
+		t.Fatalf("Devices = %v", data.Devices)
+	}
+	device := data.Devices[0]
+	if len(device) != 2 || string(device["DeviceId"]) != "545002" || string(device["ActiveScenario"]) != "1" {
+		t.Errorf("projected device = %v, want only DeviceId and ActiveScenario", device)
+	}
+}
+
+func TestGetDevicesExtendedWithoutFields(t *testing.T) {
+	h := newTestMux(t)
+	data := struct{ Devices []map[string]json.RawMessage }{}
+	mustCall(t, h, inimcloud.MethodGetDevicesExtended, nil, &data)
+	for _, field := range []string{"DeviceId", "Name", "Scenarios", "Ares", "Zones", "ActiveScenario"} {
+		if _, ok := data.Devices[0][field]; !ok {
+			t.Errorf("%s missing from the full device", field)
+		}
+	}
+}
+
+func TestGetCapabilities(t *testing.T) {
+	setFlag(t, asyncActivation, true)
+	h := newTestMux(t)
+	caps := struct {
+		ApiVersion string
+		Methods    []inimcloud.Method
+		Features   map[string]bool
+	}{}
+	mustCall(t, h, inimcloud.MethodGetCapabilities, nil, &caps)
+
+	if caps.ApiVersion != ApiVersion {
+		t.Errorf("ApiVersion = %q, want %q", caps.ApiVersion, ApiVersion)
+	}
+	if len(caps.Methods) != len(handlers) {
+		t.Errorf("Methods = %v, want every registered method", caps.Methods)
+	}
+	for _, method := range caps.Methods {
+		if _, ok := handlers[method]; !ok {
+			t.Errorf("unregistered method %s listed", method)
+		}
+	}
+	if !caps.Features["AsyncActivation"] || caps.Features["EventualReads"] {
+		t.Errorf("Features = %v, want only AsyncActivation on", caps.Features)
+	}
+}
+
+func TestActivateScenarioDryRun(t *testing.T) {
+	h := newTestMux(t)
+	before := getDevice(t, h)
+
+	data := map[string]any{}
+	mustCall(t, h, inimcloud.MethodActivateScenario, map[string]any{"DeviceId": testDevice, "ScenarioId": 2, "DryRun": true}, &data)
+	if data["DryRun"] != true {
+		t.Errorf("dry run = %v", data)
+	}
+	after := getDevice(t, h)
+	if after.ActiveScenario != before.ActiveScenario || after.Version != before.Version {
+		t.Errorf("dry run changed the device: %+v", after)
+	}
+
+	if status := callStatus(t, h, inimcloud.MethodActivateScenario, map[string]any{"DeviceId": testDevice, "ScenarioId": 9, "DryRun": true}); status != int(ErrUnknownScenario) {
+		t.Errorf("dry run of an unknown scenario = %d, want %d", status, ErrUnknownScenario)
+	}
+
+	for _, dryRun := range []any{"yes", "", []any{true}} {
+		got := validationFields(t, h, inimcloud.MethodActivateScenario, map[string]any{"DeviceId": testDevice, "ScenarioId": 2, "DryRun": dryRun})
+		if want := []inimcloud.FieldError{{Field: "DryRun", Reason: "must be a boolean"}}; !slices.Equal(got, want) {
+			t.Errorf("DryRun %v: fields = %+v, want %+v", dryRun, got, want)
+		}
+	}
+	if got := getDevice(t, h); got.ActiveScenario != before.ActiveScenario || got.Version != before.Version {
+		t.Errorf("invalid DryRun changed the device: %+v", got)
+	}
+}
+
+func TestActivateScenarioValidation(t *testing.T) {
+	h := newTestMux(t)
+	tests := []struct {
+		name   string
+		params map[string]any
+		want   ErrorCode
+	}{
+		{"unknown device", map[string]any{"DeviceId": 1, "ScenarioId": 0}, ErrUnknownDevice},
+		{"unknown scenario", activate(9), ErrUnknownScenario},
+	}
+	for _, tt := range tests {
+		if status := callStatus(t, h, inimcloud.MethodActivateScenario, tt.params); status != int(tt.want) {
+			t.Errorf("%s: Status = %d, want %d", tt.name, status, tt.want)
+		}
+	}
+}
+
+func TestActivateScenarioIfVersion(t *testing.T) {
+	h := newTestMux(t)
+	version := getDevice(t, h).Version
+
+	data := struct{ Version int }{}
+	mustCall(t, h, inimcloud.MethodActivateScenario, map[string]any{"DeviceId": testDevice, "ScenarioId": 2, "IfVersion": version}, &data)
+	if data.Version != version+1 || getDevice(t, h).Version != data.Version {
+		t.Errorf("Version after activation = %d, want %d", data.Version, version+1)
+	}
+
+	stale := map[string]any{"DeviceId": testDevice, "ScenarioId": 0, "IfVersion": version}
+	if status := callStatus(t, h, inimcloud.MethodActivateScenario, stale); status != int(ErrVersionConflict) {
+		t.Errorf("stale IfVersion = %d, want %d", status, ErrVersionConflict)
+	}
+	if got := getDevice(t, h).ActiveScenario; got != 2 {
+		t.Errorf("scenario after a conflict = %d, want 2", got)
+	}
+}
+
+func TestActivateScenarioMalformedIfVersion(t *testing.T) {
+	h := newTestMux(t)
+	before := getDevice(t, h)
+	for _, ifVersion := range []any{"v3", 3.5, true} {
+		got := validationFields(t, h, inimcloud.MethodActivateScenario, map[string]any{"DeviceId": testDevice, "ScenarioId": 2, "IfVersion": ifVersion})
+		if want := []inimcloud.FieldError{{Field: "IfVersion", Reason: "must be an integer"}}; !slices.Equal(got, want) {
+			t.Errorf("IfVersion %v: fields = %+v, want %+v", ifVersion, got, want)
+		}
+	}
+	if got := getDevice(t, h); got.ActiveScenario != before.ActiveScenario || got.Version != before.Version {
+		t.Errorf("malformed IfVersion changed the device: %+v", got)
+	}
+}
+
+func TestGetSystemTimeClockSkew(t *testing.T) {
+	fake := useFakeClock(t)
+	setFlag(t, clockSkew, -90*time.Second)
+	h := newTestMux(t)
+
+	data := struct{ SystemTime string }{}
+	mustCall(t, h, inimcloud.MethodGetSystemTime, nil, &data)
+	want := fake.Now().Add(-90 * time.Second).Format(time.RFC3339)
+	if data.SystemTime != want {
+		t.Errorf("SystemTime = %s, want %s", data.SystemTime, want)
+	}
+}
+
+func TestGetDevicesExtendedPadding(t *testing.T) {
+	setFlag(t, padBytes, 4096)
+	h := newTestMux(t)
+	data := struct{ Padding string }{}
+	mustCall(t, h, inimcloud.MethodGetDevicesExtended, nil, &data)
+	if len(data.Padding) != 4096 {
+		t.Errorf("Padding is %d bytes, want 4096", len(data.Padding))
+	}
+
+	setFlag(t, padBytes, 0)
+	raw := map[string]json.RawMessage{}
+	mustCall(t, h, inimcloud.MethodGetDevicesExtended, nil, &raw)
+	if _, ok := raw["Padding"]; ok {
+		t.Error("Padding sent without -pad-bytes")
+	}
+}
+
+func TestActiveScenarioName(t *testing.T) {
+	h := newTestMux(t)
+	if device := getDevice(t, h); device.ActiveScenarioName != "DISARM" {
+		t.Errorf("ActiveScenarioName = %q, want DISARM", device.ActiveScenarioName)
+	}
+	mustCall(t, h, inimcloud.MethodActivateScenario, activate(2), nil)
+	if device := getDevice(t, h); device.ActiveScenario != 2 || device.ActiveScenarioName != "STAY" {
+		t.Errorf("active scenario = %d %q, want 2 STAY", device.ActiveScenario, device.ActiveScenarioName)
+	}
+}
+
+func TestGetDevicesExtendedChangedSince(t *testing.T) {
+	c := useFakeClock(t)
+	h := newTestMux(t)
+	store = NewMemoryStore(manyDevices(3))
+	store.MarkChanged(1)
+	store.MarkChanged(3)
+
+	c.Advance(time.Second)
+	since := c.Now().Format(time.RFC3339Nano)
+	c.Advance(time.Second)
+	mustCall(t, h, inimcloud.MethodActivateScenario, map[string]any{"DeviceId": 2, "ScenarioId": 2}, nil)
+
+	data := struct {
+		Devices    []inimcloud.DeviceView
+		ServerTime string
+	}{}
+	mustCall(t, h, inimcloud.MethodGetDevicesExtended, map[string]any{"ChangedSince": since}, &data)
+	if len(data.Devices) != 1 || data.Devices[0].DeviceId != 2 {
+		t.Errorf("ChangedSince devices = %+v, want only device 2", data.Devices)
+	}
+	if data.ServerTime != c.Now().Format(time.RFC3339Nano) {
+		t.Errorf("ServerTime = %q, want %s", data.ServerTime, c.Now())
+	}
+
+	data.Devices = nil
+	mustCall(t, h, inimcloud.MethodGetDevicesExtended, map[string]any{"ChangedSince": data.ServerTime}, &data)
+	if len(data.Devices) != 0 {
+		t.Errorf("devices unchanged since the marker = %+v, want none", data.Devices)
+	}
+
+	rec, _ := callApi(t, h, inimcloud.Request{Method: inimcloud.MethodGetDevicesExtended, Params: map[string]any{"ChangedSince": "yesterday"}})
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid ChangedSince = %d, want 400", rec.Code)
+	}
+}
+
+func TestCaseInsensitiveMethods(t *testing.T) {
+	h := newTestMux(t)
+	req := inimcloud.Request{Method: "activatescenario", Params: activate(2)}
+	if rec, reply := callApi(t, h, req); rec.Code != http.StatusBadRequest || reply.Error != "Unknown method" {
+		t.Errorf("lowercased method by default = %d %s, want 400", rec.Code, rec.Body)
+	}
+
+	setFlag(t, caseInsensitiveMethods, true)
+	if rec, reply := callApi(t, h, req); rec.Code != http.StatusOK || reply.Status != 0 {
+		t.Fatalf("lowercased method with -case-insensitive-methods = %d %s", rec.Code, rec.Body)
+	}
+	if device := getDevice(t, h); device.ActiveScenario != 2 {
+		t.Errorf("ActiveScenario = %d, want 2", device.ActiveScenario)
+	}
+	if rec, _ := callApi(t, h, inimcloud.Request{Method: "ActivateScenarios"}); rec.Code != http.StatusBadRequest {
+		t.Errorf("unknown method with -case-insensitive-methods = %d, want 400", rec.Code)
+	}
+}
+
+func TestLookupMethodCanonicalizes(t *testing.T) {
+	setFlag(t, caseInsensitiveMethods, true)
+	if method, ok := LookupMethod("GETSYSTEMTIME"); !ok || method != inimcloud.MethodGetSystemTime {
+		t.Errorf("LookupMethod(GETSYSTEMTIME) = %q, %v", method, ok)
+	}
+}
+
+func TestGetDevicesExtendedIncludeTime(t *testing.T) {
+	fake := useFakeClock(t)
+	setFlag(t, clockSkew, 2*time.Minute)
+	h := newTestMux(t)
+
+	data := struct {
+		Devices    []inimcloud.DeviceView
+		ServerTime string
+	}{}
+	mustCall(t, h, inimcloud.MethodGetDevicesExtended, map[string]any{"IncludeTime": true}, &data)
+	serverTime, err := time.Parse(time.RFC3339Nano, data.ServerTime)
+	if err != nil || !serverTime.Equal(fake.Now()) {
+		t.Fatalf("ServerTime = %q, want %s", data.ServerTime, fake.Now())
+	}
+	deviceTime, err := time.Parse(time.RFC3339, data.Devices[0].DeviceTime)
+	if err != nil || deviceTime.Sub(serverTime) != 2*time.Minute {
+		t.Errorf("DeviceTime = %q, want ServerTime skewed by 2m", data.Devices[0].DeviceTime)
+	}
+}
+
+func TestGetDevicesExtendedWithoutIncludeTime(t *testing.T) {
+	h := newTestMux(t)
+	data := map[string]json.RawMessage{}
+	mustCall(t, h, inimcloud.MethodGetDevicesExtended, nil, &data)
+	if _, ok := data["ServerTime"]; ok {
+		t.Error("ServerTime sent without IncludeTime")
+	}
+	if device := getDevice(t, h); device.DeviceTime != "" {
+		t.Errorf("DeviceTime = %q sent without IncludeTime", device.DeviceTime)
+	}
+}
+
+func TestGetDevicesExtendedPaging(t *testing.T) {
+	h := newTestMux(t)
+	store = NewMemoryStore(manyDevices(5))
+
+	data := map[string]json.RawMessage{}
+	mustCall(t, h, inimcloud.MethodGetDevicesExtended, nil, &data)
+	if _, ok := data["Total"]; ok {
+		t.Error("Total sent without Offset or Limit")
+	}
+
+	page := struct {
+		Devices              []inimcloud.DeviceView
+		Offset, Limit, Total int
+	}{}
+	mustCall(t, h, inimcloud.MethodGetDevicesExtended, map[string]any{"Offset": 4, "Limit": 2}, &page)
+	if len(page.Devices) != 1 || page.Devices[0].DeviceId != 5 || page.Total != 5 || page.Offset != 4 || page.Limit != 2 {
+		t.Errorf("last page = %+v", page)
+	}
+	page.Devices = nil
+	mustCall(t, h, inimcloud.MethodGetDevicesExtended, map[string]any{"Offset": 9}, &page)
+	if len(page.Devices) != 0 || page.Total != 5 {
+		t.Errorf("page past the end = %+v", page)
+	}
+
+	got := validationFields(t, h, inimcloud.MethodGetDevicesExtended, map[string]any{"Offset": -1, "Limit": 0})
+	if len(got) != 2 {
+		t.Errorf("fields = %+v, want Offset and Limit", got)
+	}
+}
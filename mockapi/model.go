@@ -0,0 +1,20 @@
+package main
+
+import "github.com/lacherogwu/ha-inim_cloud/mockapi/inimcloud"
+
+// Device is a device of the catalog: what the API reports about it, plus the
+// defaults the mock's own features start from.
+type Device struct {
+	inimcloud.Device
+	// FirmwareVersion is the version the device starts with.
+	FirmwareVersion string `json:"FirmwareVersion"`
+	// Notifications sets the device's default for some event types. Those
+	// missing from it are enabled.
+	Notifications map[NotificationEvent]bool `json:"Notifications"`
+	// DisarmScenarios are the scenarios that take effect without an exit
+	// delay.
+	DisarmScenarios []int `json:"DisarmScenarios"`
+	// Account, if set, restricts the device to the sessions of that
+	// account.
+	Account string `json:"Account,omitempty"`
+}
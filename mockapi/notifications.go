@@ -0,0 +1,86 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/lacherogwu/ha-inim_cloud/mockapi/inimcloud"
+)
+
+// Panels push notifications for a configurable set of event types. Settings
+// are kept per device; events a device hasn't configured fall back to its
+// Notifications defaults, and are enabled when it has none.
+
+type NotificationEvent string
+
+const (
+	NotifyAlarm      NotificationEvent = "Alarm"
+	NotifyArmed      NotificationEvent = "Armed"
+	NotifyDisarmed   NotificationEvent = "Disarmed"
+	NotifyTamper     NotificationEvent = "Tamper"
+	NotifyLowBattery NotificationEvent = "LowBattery"
+	NotifyCommsFault NotificationEvent = "CommsFault"
+)
+
+var notificationEvents = []NotificationEvent{
+	NotifyAlarm, NotifyArmed, NotifyDisarmed, NotifyTamper, NotifyLowBattery, NotifyCommsFault,
+}
+
+// NotificationSettings returns the effective setting of every event type for
+// the device. The caller must hold stateMu.
+func NotificationSettings(device Device) map[NotificationEvent]bool {
+	settings := make(map[NotificationEvent]bool, len(notificationEvents))
+	for _, event := range notificationEvents {
+		enabled, ok := device.Notifications[event]
+		settings[event] = enabled || !ok
+	}
+	for event, enabled := range store.NotificationSettings(device.DeviceId) {
+		settings[event] = enabled
+	}
+	return settings
+}
+
+func HandleGetNotificationSettings(w http.ResponseWriter, reqData *inimcloud.Request) {
+	v := validateParams(reqData.Params)
+	deviceId := v.Int("DeviceId")
+	if !v.Check(w) {
+		return
+	}
+	stateMu.Lock()
+	defer stateMu.Unlock()
+
+	device, found := store.Device(deviceId)
+	if !found {
+		WriteStatus(w, ErrUnknownDevice, "Device not found")
+		return
+	}
+	WriteJson(w, map[string]any{"Settings": NotificationSettings(device)})
+}
+
+// HandleSetNotificationSettings updates the event types named in Settings and
+// leaves the others as they are.
+func HandleSetNotificationSettings(w http.ResponseWriter, reqData *inimcloud.Request) {
+	v := validateParams(reqData.Params)
+	deviceId := v.Int("DeviceId")
+	changes := v.BoolMap("Settings")
+	for name := range changes {
+		if !slices.Contains(notificationEvents, NotificationEvent(name)) {
+			v.Reject("Settings."+name, "is not a known event type")
+		}
+	}
+	if !v.Check(w) {
+		return
+	}
+	stateMu.Lock()
+	defer stateMu.Unlock()
+
+	device, found := store.Device(deviceId)
+	if !found {
+		WriteStatus(w, ErrUnknownDevice, "Device not found")
+		return
+	}
+	for name, enabled := range changes {
+		store.SetNotificationSetting(deviceId, NotificationEvent(name), enabled)
+	}
+	WriteJson(w, map[string]any{"Settings": NotificationSettings(device)})
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/lacherogwu/ha-inim_cloud/mockapi/inimcloud"
+)
+
+func getNotifications(t *testing.T, h http.Handler) map[NotificationEvent]bool {
+	t.Helper()
+	data := struct{ Settings map[NotificationEvent]bool }{}
+	mustCall(t, h, inimcloud.MethodGetNotificationSettings, map[string]any{"DeviceId": testDevice}, &data)
+	return data.Settings
+}
+
+func TestNotificationSettingsRoundTrip(t *testing.T) {
+	h := newTestMux(t)
+	if settings := getNotifications(t, h); !settings[NotifyTamper] {
+		t.Errorf("settings = %v, want Tamper enabled by default", settings)
+	}
+
+	mustCall(t, h, inimcloud.MethodSetNotificationSettings, map[string]any{
+		"DeviceId": testDevice,
+		"Settings": map[string]any{"Tamper": false},
+	}, nil)
+	settings := getNotifications(t, h)
+	if settings[NotifyTamper] || !settings[NotifyAlarm] {
+		t.Errorf("after turning Tamper off: %v", settings)
+	}
+	if len(settings) != len(notificationEvents) {
+		t.Errorf("%d settings, want one per event type", len(settings))
+	}
+}
+
+func TestNotificationSettingsFixtureDefaults(t *testing.T) {
+	h := newTestMux(t)
+	devices := slices.Clone(defaultDevices)
+	devices[0].Notifications = map[NotificationEvent]bool{NotifyLowBattery: false, NotifyArmed: true}
+	store = NewMemoryStore(devices, map[int]int{testDevice: 1})
+
+	settings := getNotifications(t, h)
+	if settings[NotifyLowBattery] || !settings[NotifyArmed] {
+		t.Errorf("settings = %v, want only LowBattery off", settings)
+	}
+
+	mustCall(t, h, inimcloud.MethodSetNotificationSettings, map[string]any{
+		"DeviceId": testDevice,
+		"Settings": map[string]any{"LowBattery": true},
+	}, nil)
+	if !getNotifications(t, h)[NotifyLowBattery] {
+		t.Error("setting did not override the fixture default")
+	}
+}
+
+func TestSetNotificationSettingsRejectsUnknownEvents(t *testing.T) {
+	h := newTestMux(t)
+	got := validationFields(t, h, inimcloud.MethodSetNotificationSettings, map[string]any{
+		"DeviceId": testDevice,
+		"Settings": map[string]any{"Doorbell": true, "Alarm": false},
+	})
+	want := []inimcloud.FieldError{{Field: "Settings.Doorbell", Reason: "is not a known event type"}}
+	if !slices.Equal(got, want) {
+		t.Errorf("fields = %+v, want %+v", got, want)
+	}
+	if !getNotifications(t, h)[NotifyAlarm] {
+		t.Error("rejected request still changed Alarm")
+	}
+}
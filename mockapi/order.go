@@ -0,0 +1,66 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lacherogwu/ha-inim_cloud/mockapi/inimcloud"
+)
+
+// Orderings accepted by the OrderBy param of GetDevicesExtended. Ties are
+// broken by device id so the order is always stable. lastActivated goes by
+// the latest scenario change in the device's history, so other changes
+// such as a zone opening don't move a device.
+var deviceOrders = map[string]func(a, b inimcloud.DeviceView) int{
+	"id": func(a, b inimcloud.DeviceView) int {
+		return cmp.Compare(a.DeviceId, b.DeviceId)
+	},
+	"name": func(a, b inimcloud.DeviceView) int {
+		return strings.Compare(a.Name, b.Name)
+	},
+	"lastActivated": func(a, b inimcloud.DeviceView) int {
+		return lastActivated(a.DeviceId).Compare(lastActivated(b.DeviceId))
+	},
+}
+
+// lastActivated returns when the device's scenario last changed, or the
+// zero time if it never did. The caller must hold stateMu.
+func lastActivated(deviceId int) time.Time {
+	entries := store.History(deviceId)
+	if len(entries) == 0 {
+		return time.Time{}
+	}
+	return entries[len(entries)-1].At
+}
+
+// SortDevices orders views by orderBy ("id" when empty) in the given
+// direction, "asc" (the default) or "desc". The caller must hold stateMu.
+func SortDevices(views []inimcloud.DeviceView, orderBy, direction string) error {
+	if orderBy == "" {
+		orderBy = "id"
+	}
+	compare, ok := deviceOrders[orderBy]
+	if !ok {
+		return fmt.Errorf("unknown ordering %q", orderBy)
+	}
+
+	sign := 1
+	switch direction {
+	case "", "asc":
+	case "desc":
+		sign = -1
+	default:
+		return fmt.Errorf("unknown direction %q", direction)
+	}
+
+	slices.SortStableFunc(views, func(a, b inimcloud.DeviceView) int {
+		if c := compare(a, b); c != 0 {
+			return sign * c
+		}
+		return cmp.Compare(a.DeviceId, b.DeviceId)
+	})
+	return nil
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/lacherogwu/ha-inim_cloud/mockapi/inimcloud"
+)
+
+// orderedDevices installs three devices whose names sort against their ids,
+// the third activated before the first. The second is changed last, but
+// never activated.
+func orderedDevices(t *testing.T) http.Handler {
+	t.Helper()
+	c := useFakeClock(t)
+	h := newTestMux(t)
+	devices, active := manyDevices(3)
+	for i, name := range []string{"Charlie", "Alpha", "Bravo"} {
+		devices[i].Name = name
+	}
+	store = NewMemoryStore(devices, active)
+	mustCall(t, h, inimcloud.MethodActivateScenario, map[string]any{"DeviceId": 3, "ScenarioId": 2}, nil)
+	c.Advance(time.Second)
+	mustCall(t, h, inimcloud.MethodActivateScenario, map[string]any{"DeviceId": 1, "ScenarioId": 2}, nil)
+	c.Advance(time.Second)
+	post(t, h, "/admin/devices/2/zones/1/open", "")
+	return h
+}
+
+func deviceOrder(t *testing.T, h http.Handler, params map[string]any) []int {
+	t.Helper()
+	data := struct{ Devices []inimcloud.DeviceView }{}
+	mustCall(t, h, inimcloud.MethodGetDevicesExtended, params, &data)
+	ids := []int{}
+	for _, device := range data.Devices {
+		ids = append(ids, device.DeviceId)
+	}
+	return ids
+}
+
+func TestOrderBy(t *testing.T) {
+	h := orderedDevices(t)
+	tests := []struct {
+		orderBy, order string
+		want           []int
+	}{
+		{"", "", []int{1, 2, 3}},
+		{"id", "desc", []int{3, 2, 1}},
+		{"name", "", []int{2, 3, 1}},
+		{"name", "desc", []int{1, 3, 2}},
+		// Device 2 was never activated, so it comes first.
+		{"lastActivated", "asc", []int{2, 3, 1}},
+		{"lastActivated", "desc", []int{1, 3, 2}},
+	}
+	for _, tt := range tests {
+		params := map[string]any{}
+		if tt.orderBy != "" {
+			params["OrderBy"] = tt.orderBy
+		}
+		if tt.order != "" {
+			params["Order"] = tt.order
+		}
+		if got := deviceOrder(t, h, params); !slices.Equal(got, tt.want) {
+			t.Errorf("OrderBy %q %q = %v, want %v", tt.orderBy, tt.order, got, tt.want)
+		}
+	}
+}
+
+func TestOrderByBreaksTiesById(t *testing.T) {
+	h := newTestMux(t)
+	store = NewMemoryStore(manyDevices(4))
+	params := map[string]any{"OrderBy": "name", "Order": "desc"}
+	for range 3 {
+		if got := deviceOrder(t, h, params); !slices.Equal(got, []int{1, 2, 3, 4}) {
+			t.Fatalf("same-named devices = %v, want id order", got)
+		}
+	}
+}
+
+func TestOrderByInvalid(t *testing.T) {
+	h := newTestMux(t)
+	for _, params := range []map[string]any{
+		{"OrderBy": "zones"},
+		{"OrderBy": "name", "Order": "sideways"},
+	} {
+		rec, _ := callApi(t, h, inimcloud.Request{Method: inimcloud.MethodGetDevicesExtended, Params: params})
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%v = %d %s, want 400", params, rec.Code, rec.Body)
+		}
+	}
+}
+
+func TestPagesFollowTheOrder(t *testing.T) {
+	h := orderedDevices(t)
+	ids := []int{}
+	total := 0
+	for offset := 0; offset < 3; offset += 2 {
+		data := struct {
+			Devices []inimcloud.DeviceView
+			Total   int
+		}{}
+		params := map[string]any{"OrderBy": "name", "Offset": offset, "Limit": 2}
+		mustCall(t, h, inimcloud.MethodGetDevicesExtended, params, &data)
+		for _, device := range data.Devices {
+			ids = append(ids, device.DeviceId)
+		}
+		total = data.Total
+	}
+	if total != 3 || !slices.Equal(ids, []int{2, 3, 1}) {
+		t.Errorf("pages by name = %v of %d, want [2 3 1] of 3", ids, total)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+
+	"github.com/lacherogwu/ha-inim_cloud/mockapi/inimcloud"
+)
+
+// A partial outage takes individual methods down: they answer 503 while the
+// rest of the API keeps working. The set starts from -outage-methods and is
+// replaced at runtime through POST /admin/outage. Both refuse methods the
+// mock doesn't serve, so a misspelled name can't leave the outage doing
+// nothing.
+var outageMethods = flag.String("outage-methods", "", "comma-separated methods to answer with 503")
+
+var (
+	outageMu sync.Mutex
+	outage   = map[inimcloud.Method]bool{}
+)
+
+type OutageState struct {
+	Methods []inimcloud.Method `json:"Methods"`
+}
+
+func SetOutage(methods []inimcloud.Method) {
+	outageMu.Lock()
+	defer outageMu.Unlock()
+
+	outage = map[inimcloud.Method]bool{}
+	for _, method := range methods {
+		outage[method] = true
+	}
+}
+
+func InOutage(method inimcloud.Method) bool {
+	outageMu.Lock()
+	defer outageMu.Unlock()
+	return outage[method]
+}
+
+func currentOutage() OutageState {
+	outageMu.Lock()
+	defer outageMu.Unlock()
+
+	state := OutageState{Methods: []inimcloud.Method{}}
+	for method := range outage {
+		state.Methods = append(state.Methods, method)
+	}
+	slices.Sort(state.Methods)
+	return state
+}
+
+func HandleOutage(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		state := OutageState{}
+		if err := json.NewDecoder(r.Body).Decode(&state); err != nil {
+			WriteError(w, http.StatusBadRequest, "Invalid outage state")
+			return
+		}
+		if unknown := UnknownMethods(state.Methods); len(unknown) > 0 {
+			WriteError(w, http.StatusBadRequest, fmt.Sprintf("Unknown methods %v", unknown))
+			return
+		}
+		SetOutage(state.Methods)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(currentOutage()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/lacherogwu/ha-inim_cloud/mockapi/inimcloud"
+)
+
+func TestOutageHitsOnlyListedMethods(t *testing.T) {
+	h := newTestMux(t)
+	SetOutage(ParseMethods(" ActivateScenario, ,SetOutput"))
+
+	rec, _ := callApi(t, h, inimcloud.Request{Method: inimcloud.MethodActivateScenario, Params: activate(2)})
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("ActivateScenario in outage = %d %s, want 503", rec.Code, rec.Body)
+	}
+	if getDevice(t, h).ActiveScenario != 1 {
+		t.Error("ActivateScenario in outage still ran")
+	}
+}
+
+func TestOutageAdmin(t *testing.T) {
+	h := newTestMux(t)
+	rec := post(t, h, "/admin/outage", `{"Methods":["GetSystemTime","ActivateScenario"]}`)
+	state := OutageState{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &state); err != nil {
+		t.Fatalf("POST /admin/outage = %d %s", rec.Code, rec.Body)
+	}
+	want := []inimcloud.Method{inimcloud.MethodActivateScenario, inimcloud.MethodGetSystemTime}
+	if !slices.Equal(state.Methods, want) {
+		t.Errorf("outage = %v, want %v", state.Methods, want)
+	}
+	if rec, _ := callApi(t, h, inimcloud.Request{Method: inimcloud.MethodGetSystemTime}); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("GetSystemTime in outage = %d", rec.Code)
+	}
+
+	post(t, h, "/admin/outage", `{"Methods":[]}`)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/outage", nil))
+	if rec.Body.String() != "{\"Methods\":[]}\n" {
+		t.Errorf("GET /admin/outage after clearing = %s", rec.Body)
+	}
+	mustCall(t, h, inimcloud.MethodActivateScenario, activate(2), nil)
+
+	if rec := post(t, h, "/admin/outage", `not json`); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid outage state = %d, want 400", rec.Code)
+	}
+}
+
+func TestOutageRejectsUnknownMethods(t *testing.T) {
+	h := newTestMux(t)
+	post(t, h, "/admin/outage", `{"Methods":["GetSystemTime"]}`)
+	if rec := post(t, h, "/admin/outage", `{"Methods":["GetFaults","GetSytemTime"]}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("outage with a misspelled method = %d %s, want 400", rec.Code, rec.Body)
+	}
+	if got := currentOutage().Methods; !slices.Equal(got, []inimcloud.Method{inimcloud.MethodGetSystemTime}) {
+		t.Errorf("outage after the refused update = %v, want it unchanged", got)
+	}
+
+	got := UnknownMethods(ParseMethods("ActivateScenario,SetOutput,GetSytemTime"))
+	if want := []inimcloud.Method{"SetOutput", "GetSytemTime"}; !slices.Equal(got, want) {
+		t.Errorf("UnknownMethods = %v, want %v", got, want)
+	}
+}
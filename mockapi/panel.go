@@ -0,0 +1,193 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/lacherogwu/ha-inim_cloud/mockapi/inimcloud"
+)
+
+// Events that happen at the panel rather than through the API, such as a
+// zone opening or an alarm going off at the keypad, are simulated through
+// the admin endpoints below. They change what GetDevicesExtended reports
+// like any other change, bumping the device's version: the Status of the
+// zone, the Alarm of the areas, and through the scenario, whether each area
+// is Armed. An alarm without an AreaId goes off in every area.
+//
+//	POST /admin/devices/{id}/zones/{zone}/open
+//	POST /admin/devices/{id}/zones/{zone}/close
+//	POST /admin/devices/{id}/alarm     {"Active": true, "AreaId": 1}
+//	POST /admin/devices/{id}/scenario  {"ScenarioId": 0}
+
+// keypadActor is recorded in the history for scenarios set at the panel.
+const keypadActor = "keypad"
+
+// AreaViews returns the device's areas with their state while scenarioId is
+// active: a disarm scenario leaves every area disarmed, any other arms the
+// areas it lists, or all of them. The caller must hold stateMu.
+func AreaViews(device Device, scenarioId int) []inimcloud.AreaView {
+	scenario, _ := device.Scenario(scenarioId)
+	disarmed := slices.Contains(device.DisarmScenarios, scenarioId)
+	views := make([]inimcloud.AreaView, 0, len(device.Areas))
+	for _, area := range device.Areas {
+		armed := !disarmed && (len(scenario.Areas) == 0 || slices.Contains(scenario.Areas, area.AreaId))
+		views = append(views, inimcloud.AreaView{
+			Area:  area,
+			Armed: armed,
+			Alarm: store.Alarm(device.DeviceId, area.AreaId),
+		})
+	}
+	return views
+}
+
+// InAlarm reports whether any area of the device is in alarm. The caller
+// must hold stateMu.
+func InAlarm(device Device) bool {
+	return slices.ContainsFunc(device.Areas, func(area inimcloud.Area) bool {
+		return store.Alarm(device.DeviceId, area.AreaId)
+	})
+}
+
+// ZoneViews returns the device's zones with their state. The caller must
+// hold stateMu.
+func ZoneViews(device Device) []inimcloud.ZoneView {
+	views := make([]inimcloud.ZoneView, 0, len(device.Zones))
+	for _, zone := range device.Zones {
+		status := inimcloud.ZoneClosed
+		if store.ZoneOpen(device.DeviceId, zone.ZoneId) {
+			status = inimcloud.ZoneOpen
+		}
+		views = append(views, inimcloud.ZoneView{Zone: zone, Status: status})
+	}
+	return views
+}
+
+func HandleOpenZone(w http.ResponseWriter, r *http.Request) {
+	setZone(w, r, true)
+}
+
+func HandleCloseZone(w http.ResponseWriter, r *http.Request) {
+	setZone(w, r, false)
+}
+
+func setZone(w http.ResponseWriter, r *http.Request, open bool) {
+	deviceId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid device id")
+		return
+	}
+	zoneId, err := strconv.Atoi(r.PathValue("zone"))
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid zone id")
+		return
+	}
+
+	stateMu.Lock()
+	defer stateMu.Unlock()
+
+	device, found := store.Device(deviceId)
+	if !found {
+		WriteError(w, http.StatusNotFound, "Device not found")
+		return
+	}
+	if !device.HasZone(zoneId) {
+		WriteError(w, http.StatusNotFound, "Zone not found")
+		return
+	}
+	if store.ZoneOpen(deviceId, zoneId) != open {
+		store.SetZoneOpen(deviceId, zoneId, open)
+		store.MarkChanged(deviceId)
+		eventType := EventZoneClosed
+		if open {
+			eventType = EventZoneOpened
+		}
+		RecordEvent(Event{DeviceId: deviceId, Type: eventType, ZoneId: &zoneId})
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func HandleAlarm(w http.ResponseWriter, r *http.Request) {
+	deviceId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid device id")
+		return
+	}
+	body := struct {
+		Active bool `json:"Active"`
+		AreaId *int `json:"AreaId"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid alarm state")
+		return
+	}
+
+	stateMu.Lock()
+	defer stateMu.Unlock()
+
+	device, found := store.Device(deviceId)
+	if !found {
+		WriteError(w, http.StatusNotFound, "Device not found")
+		return
+	}
+	areas := device.Areas
+	if body.AreaId != nil {
+		area, ok := device.Area(*body.AreaId)
+		if !ok {
+			WriteError(w, http.StatusNotFound, "Area not found")
+			return
+		}
+		areas = []inimcloud.Area{area}
+	}
+	changed := false
+	for _, area := range areas {
+		if store.Alarm(deviceId, area.AreaId) == body.Active {
+			continue
+		}
+		store.SetAlarm(deviceId, area.AreaId, body.Active)
+		changed = true
+		eventType := EventAlarmCleared
+		if body.Active {
+			eventType = EventAlarmRaised
+		}
+		RecordEvent(Event{DeviceId: deviceId, Type: eventType, AreaId: &area.AreaId})
+	}
+	if changed {
+		store.MarkChanged(deviceId)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleSetScenario changes the scenario as if from the keypad, skipping the
+// checks an API activation goes through other than the scenario existing.
+// Arming from the keypad counts down the exit delay all the same.
+func HandleSetScenario(w http.ResponseWriter, r *http.Request) {
+	deviceId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid device id")
+		return
+	}
+	body := struct {
+		ScenarioId *int `json:"ScenarioId"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.ScenarioId == nil {
+		WriteError(w, http.StatusBadRequest, "Invalid scenario")
+		return
+	}
+
+	stateMu.Lock()
+	defer stateMu.Unlock()
+
+	device, found := store.Device(deviceId)
+	if !found {
+		WriteError(w, http.StatusNotFound, "Device not found")
+		return
+	}
+	if _, ok := device.Scenario(*body.ScenarioId); !ok {
+		WriteError(w, http.StatusNotFound, "Scenario not found")
+		return
+	}
+	Activate(device, *body.ScenarioId, keypadActor)
+	w.WriteHeader(http.StatusNoContent)
+}
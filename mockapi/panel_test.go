@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/lacherogwu/ha-inim_cloud/mockapi/inimcloud"
+)
+
+// twoAreaPanel installs the test device with a second area, armed alone by
+// a NIGHT scenario.
+func twoAreaPanel(t *testing.T) http.Handler {
+	t.Helper()
+	h := newTestMux(t)
+	device := defaultDevices[0]
+	device.Scenarios = append(slices.Clone(device.Scenarios), inimcloud.Scenario{ScenarioId: 3, Name: "NIGHT", Areas: []int{2}})
+	device.Areas = []inimcloud.Area{{AreaId: 1, Name: "House"}, {AreaId: 2, Name: "Garage"}}
+	store = NewMemoryStore([]Device{device}, map[int]int{testDevice: 1})
+	return h
+}
+
+func armed(device inimcloud.DeviceView) []bool {
+	list := []bool{}
+	for _, area := range device.Areas {
+		list = append(list, area.Armed)
+	}
+	return list
+}
+
+func TestAreasArmedByScenario(t *testing.T) {
+	h := twoAreaPanel(t)
+	tests := []struct {
+		scenarioId int
+		want       []bool
+	}{
+		{1, []bool{false, false}},
+		{0, []bool{true, true}},
+		{3, []bool{false, true}},
+	}
+	for _, tt := range tests {
+		if rec := post(t, h, "/admin/devices/545002/scenario", fmt.Sprintf(`{"ScenarioId": %d}`, tt.scenarioId)); rec.Code != http.StatusNoContent {
+			t.Fatalf("POST scenario %d = %d %s", tt.scenarioId, rec.Code, rec.Body)
+		}
+		if got := armed(getDevice(t, h)); !slices.Equal(got, tt.want) {
+			t.Errorf("scenario %d: Armed = %v, want %v", tt.scenarioId, got, tt.want)
+		}
+	}
+}
+
+func TestZoneStatus(t *testing.T) {
+	h := newTestMux(t)
+	before := getDevice(t, h)
+	if before.Zones[0].Status != inimcloud.ZoneClosed {
+		t.Fatalf("zone starts %d, want closed", before.Zones[0].Status)
+	}
+
+	post(t, h, "/admin/devices/545002/zones/1/open", "")
+	post(t, h, "/admin/devices/545002/zones/1/open", "")
+	after := getDevice(t, h)
+	if after.Zones[0].Status != inimcloud.ZoneOpen || after.Version != before.Version+1 {
+		t.Errorf("after opening twice: status %d, version %d, want open and %d", after.Zones[0].Status, after.Version, before.Version+1)
+	}
+
+	post(t, h, "/admin/devices/545002/zones/1/close", "")
+	if status := getDevice(t, h).Zones[0].Status; status != inimcloud.ZoneClosed {
+		t.Errorf("after closing: status %d", status)
+	}
+	for path, want := range map[string]int{
+		"/admin/devices/545002/zones/9/open": http.StatusNotFound,
+		"/admin/devices/1/zones/1/open":      http.StatusNotFound,
+		"/admin/devices/545002/zones/x/open": http.StatusBadRequest,
+	} {
+		if rec := post(t, h, path, ""); rec.Code != want {
+			t.Errorf("POST %s = %d, want %d", path, rec.Code, want)
+		}
+	}
+}
+
+func TestAlarmPerArea(t *testing.T) {
+	h := twoAreaPanel(t)
+	post(t, h, "/admin/devices/545002/alarm", `{"Active": true, "AreaId": 2}`)
+	device := getDevice(t, h)
+	if !device.Alarm || device.Areas[0].Alarm || !device.Areas[1].Alarm {
+		t.Errorf("after an alarm in area 2: %+v", device.Areas)
+	}
+
+	post(t, h, "/admin/devices/545002/alarm", `{"Active": true}`)
+	post(t, h, "/admin/devices/545002/alarm", `{"Active": false, "AreaId": 2}`)
+	device = getDevice(t, h)
+	if !device.Areas[0].Alarm || device.Areas[1].Alarm {
+		t.Errorf("after clearing area 2 only: %+v", device.Areas)
+	}
+
+	data := struct{ Events []Event }{}
+	mustCall(t, h, inimcloud.MethodGetEventsLatest, nil, &data)
+	want := []struct {
+		eventType EventType
+		areaId    int
+	}{{EventAlarmRaised, 2}, {EventAlarmRaised, 1}, {EventAlarmCleared, 2}}
+	if len(data.Events) != len(want) {
+		t.Fatalf("events = %+v, want %d", data.Events, len(want))
+	}
+	for i, w := range want {
+		event := data.Events[i]
+		if event.Type != w.eventType || event.AreaId == nil || *event.AreaId != w.areaId {
+			t.Errorf("event %d = %+v, want %s in area %d", i, event, w.eventType, w.areaId)
+		}
+	}
+
+	if rec := post(t, h, "/admin/devices/545002/alarm", `{"Active": true, "AreaId": 5}`); rec.Code != http.StatusNotFound {
+		t.Errorf("alarm in an unknown area = %d, want 404", rec.Code)
+	}
+}
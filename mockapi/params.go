@@ -0,0 +1,159 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/lacherogwu/ha-inim_cloud/mockapi/inimcloud"
+)
+
+// Params arrive as decoded JSON, so numbers are float64 and clients are free
+// to send ids either as numbers or as strings (the Python integration sends
+// strings). These helpers coerce both forms and report false when the key is
+// missing or the value can't be converted.
+
+func paramInt(params map[string]any, key string) (int, bool) {
+	switch v := params[key].(type) {
+	case float64:
+		if v != float64(int(v)) {
+			return 0, false
+		}
+		return int(v), true
+	case string:
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return 0, false
+		}
+		return n, true
+	}
+	return 0, false
+}
+
+func paramString(params map[string]any, key string) (string, bool) {
+	switch v := params[key].(type) {
+	case string:
+		return v, true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(v), true
+	}
+	return "", false
+}
+
+func paramBool(params map[string]any, key string) (bool, bool) {
+	switch v := params[key].(type) {
+	case bool:
+		return v, true
+	case string:
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return false, false
+		}
+		return b, true
+	case float64:
+		return v != 0, true
+	}
+	return false, false
+}
+
+func paramStrings(params map[string]any, key string) ([]string, bool) {
+	list, ok := params[key].([]any)
+	if !ok {
+		return nil, false
+	}
+
+	values := make([]string, 0, len(list))
+	for _, v := range list {
+		if s, ok := v.(string); ok {
+			values = append(values, s)
+		}
+	}
+	return values, true
+}
+
+// paramValidator reads required params, collecting every problem instead of
+// stopping at the first, so they can all be reported at once.
+type paramValidator struct {
+	params map[string]any
+	errors []inimcloud.FieldError
+}
+
+func validateParams(params map[string]any) *paramValidator {
+	return &paramValidator{params: params}
+}
+
+func (v *paramValidator) Int(key string) int {
+	n, ok := paramInt(v.params, key)
+	if !ok {
+		v.fail(key, "must be an integer")
+	}
+	return n
+}
+
+// OptionalInt reads an integer param that may be left out. It reports
+// whether the param was given and valid.
+func (v *paramValidator) OptionalInt(key string) (int, bool) {
+	if _, present := v.params[key]; !present {
+		return 0, false
+	}
+	n, ok := paramInt(v.params, key)
+	if !ok {
+		v.fail(key, "must be an integer")
+	}
+	return n, ok
+}
+
+// OptionalBool reads a boolean param that may be left out, as false.
+func (v *paramValidator) OptionalBool(key string) bool {
+	if _, present := v.params[key]; !present {
+		return false
+	}
+	b, ok := paramBool(v.params, key)
+	if !ok {
+		v.fail(key, "must be a boolean")
+	}
+	return b
+}
+
+// BoolMap reads an object whose values are all booleans.
+func (v *paramValidator) BoolMap(key string) map[string]bool {
+	obj, ok := v.params[key].(map[string]any)
+	if !ok {
+		v.fail(key, "must be an object of booleans")
+		return nil
+	}
+
+	values := make(map[string]bool, len(obj))
+	for name, value := range obj {
+		b, ok := value.(bool)
+		if !ok {
+			v.Reject(key+"."+name, "must be a boolean")
+			continue
+		}
+		values[name] = b
+	}
+	return values
+}
+
+// Reject records a param that was read but failed a check of the caller's.
+func (v *paramValidator) Reject(field, reason string) {
+	v.errors = append(v.errors, inimcloud.FieldError{Field: field, Reason: reason})
+}
+
+func (v *paramValidator) fail(key, reason string) {
+	if _, present := v.params[key]; !present {
+		reason = "is required"
+	}
+	v.Reject(key, reason)
+}
+
+// Check writes a validation error listing every invalid param, and reports
+// whether all params were valid.
+func (v *paramValidator) Check(w http.ResponseWriter) bool {
+	if len(v.errors) == 0 {
+		return true
+	}
+	WriteValidationError(w, v.errors)
+	return false
+}
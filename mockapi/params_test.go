@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/lacherogwu/ha-inim_cloud/mockapi/inimcloud"
+)
+
+// decodeParams decodes params the way requests are, so numbers are float64.
+func decodeParams(t *testing.T, raw string) map[string]any {
+	t.Helper()
+	params := map[string]any{}
+	if err := json.Unmarshal([]byte(raw), &params); err != nil {
+		t.Fatal(err)
+	}
+	return params
+}
+
+func TestParamInt(t *testing.T) {
+	params := decodeParams(t, `{"num": 545002, "str": "545002", "frac": 1.5, "bad": "x1", "bool": true}`)
+	tests := []struct {
+		key    string
+		want   int
+		wantOk bool
+	}{
+		{"num", 545002, true},
+		{"str", 545002, true},
+		{"frac", 0, false},
+		{"bad", 0, false},
+		{"bool", 0, false},
+		{"missing", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := paramInt(params, tt.key)
+		if got != tt.want || ok != tt.wantOk {
+			t.Errorf("paramInt(%s) = %d, %v, want %d, %v", tt.key, got, ok, tt.want, tt.wantOk)
+		}
+	}
+}
+
+func TestParamString(t *testing.T) {
+	params := decodeParams(t, `{"str": "abc", "num": 17301503, "frac": 0.5, "bool": false, "list": []}`)
+	tests := []struct {
+		key    string
+		want   string
+		wantOk bool
+	}{
+		{"str", "abc", true},
+		{"num", "17301503", true},
+		{"frac", "0.5", true},
+		{"bool", "false", true},
+		{"list", "", false},
+		{"missing", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := paramString(params, tt.key)
+		if got != tt.want || ok != tt.wantOk {
+			t.Errorf("paramString(%s) = %q, %v, want %q, %v", tt.key, got, ok, tt.want, tt.wantOk)
+		}
+	}
+}
+
+func TestParamBool(t *testing.T) {
+	params := decodeParams(t, `{"bool": true, "str": "false", "one": 1, "zero": 0, "bad": "maybe"}`)
+	tests := []struct {
+		key    string
+		want   bool
+		wantOk bool
+	}{
+		{"bool", true, true},
+		{"str", false, true},
+		{"one", true, true},
+		{"zero", false, true},
+		{"bad", false, false},
+		{"missing", false, false},
+	}
+	for _, tt := range tests {
+		got, ok := paramBool(params, tt.key)
+		if got != tt.want || ok != tt.wantOk {
+			t.Errorf("paramBool(%s) = %v, %v, want %v, %v", tt.key, got, ok, tt.want, tt.wantOk)
+		}
+	}
+}
+
+func TestParamStringsSkipsNonStrings(t *testing.T) {
+	params := decodeParams(t, `{"list": ["Name", 3, "Version"], "str": "Name"}`)
+	got, ok := paramStrings(params, "list")
+	if !ok || len(got) != 2 || got[0] != "Name" || got[1] != "Version" {
+		t.Errorf("paramStrings(list) = %v, %v", got, ok)
+	}
+	if _, ok := paramStrings(params, "str"); ok {
+		t.Error("paramStrings accepted a string")
+	}
+}
+
+func TestStringIdsAsSentByIntegration(t *testing.T) {
+	h := newTestMux(t)
+	mustCall(t, h, inimcloud.MethodActivateScenario, map[string]any{"DeviceId": "545002", "ScenarioId": "2"}, nil)
+	if got := getDevice(t, h).ActiveScenario; got != 2 {
+		t.Errorf("scenario = %d, want 2", got)
+	}
+}
+
+// validationFields sends method and returns the fields its 400 reply
+// reports as invalid.
+func validationFields(t *testing.T, h http.Handler, method inimcloud.Method, params map[string]any) []inimcloud.FieldError {
+	t.Helper()
+	rec, reply := callApi(t, h, inimcloud.Request{Method: method, Params: params})
+	if rec.Code != http.StatusBadRequest || reply.Error != "Invalid params" {
+		t.Fatalf("%s(%v) = %d %s, want a validation error", method, params, rec.Code, rec.Body)
+	}
+	body := struct{ Fields []inimcloud.FieldError }{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	return body.Fields
+}
+
+func TestValidationErrorListsEveryField(t *testing.T) {
+	h := newTestMux(t)
+	got := validationFields(t, h, inimcloud.MethodActivateScenario, map[string]any{"DeviceId": "abc"})
+	want := []inimcloud.FieldError{
+		{Field: "DeviceId", Reason: "must be an integer"},
+		{Field: "ScenarioId", Reason: "is required"},
+	}
+	slices.SortFunc(got, func(a, b inimcloud.FieldError) int { return strings.Compare(a.Field, b.Field) })
+	if !slices.Equal(got, want) {
+		t.Errorf("fields = %+v, want %+v", got, want)
+	}
+}
+
+func TestValidationErrorFieldPaths(t *testing.T) {
+	h := newTestMux(t)
+	got := validationFields(t, h, inimcloud.MethodSetNotificationSettings, map[string]any{
+		"DeviceId": testDevice,
+		"Settings": map[string]any{"Alarm": "yes"},
+	})
+	want := []inimcloud.FieldError{{Field: "Settings.Alarm", Reason: "must be a boolean"}}
+	if !slices.Equal(got, want) {
+		t.Errorf("fields = %+v, want %+v", got, want)
+	}
+}
+
+func TestValidationErrorOverRpc(t *testing.T) {
+	h := newTestMux(t)
+	rec := callRpcOver(t, h, `{"jsonrpc":"2.0","id":1,"method":"ActivateScenario","params":{"DeviceId":"abc","ScenarioId":1}}`, "")
+	res := struct {
+		Error struct {
+			Code int
+			Data struct{ Fields []inimcloud.FieldError }
+		}
+	}{}
+	decodeRpc(t, rec, &res)
+	want := []inimcloud.FieldError{{Field: "DeviceId", Reason: "must be an integer"}}
+	if res.Error.Code != RpcInvalidParams || !slices.Equal(res.Error.Data.Fields, want) {
+		t.Errorf("JSON-RPC error = %+v, want %d with %+v", res.Error, RpcInvalidParams, want)
+	}
+}
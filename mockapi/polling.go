@@ -0,0 +1,84 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+	"time"
+
+	"github.com/lacherogwu/ha-inim_cloud/mockapi/inimcloud"
+)
+
+// GetDevicesExtended replies suggest when to poll next in an X-Poll-Interval
+// header, in seconds. Right after a change the hint is -poll-min; every read
+// that finds nothing new doubles it, up to -poll-max. Each client, told
+// apart by its ClientId or session, backs off on its own, and only reads of
+// a first page count: fetching the further pages of a poll is the same poll.
+var (
+	pollMin = flag.Duration("poll-min", 5*time.Second, "poll interval suggested right after a change")
+	pollMax = flag.Duration("poll-max", time.Minute, "longest poll interval suggested while nothing changes")
+)
+
+// pollState is what a client's last counted read found.
+type pollState struct {
+	fingerprint    int
+	unchangedReads int
+}
+
+// polls is keyed by client, guarded by stateMu.
+var polls = map[string]*pollState{}
+
+// NextPollInterval returns the interval to suggest to client for a read of
+// the current state, counting the read when it is of a first page. The
+// caller must hold stateMu.
+func NextPollInterval(client string, firstPage bool) time.Duration {
+	fingerprint := 0
+	for _, device := range store.Devices() {
+		fingerprint += store.Version(device.DeviceId)
+	}
+
+	poll := polls[client]
+	switch {
+	case poll == nil:
+		poll = &pollState{fingerprint: fingerprint}
+		polls[client] = poll
+	case !firstPage:
+	case fingerprint != poll.fingerprint:
+		poll.fingerprint = fingerprint
+		poll.unchangedReads = 0
+	default:
+		poll.unchangedReads++
+	}
+
+	interval := *pollMin
+	for range poll.unchangedReads {
+		if interval >= *pollMax {
+			break
+		}
+		interval *= 2
+	}
+	return min(interval, *pollMax)
+}
+
+// HandleRequestPoll answers the integration asking the cloud to fetch a
+// panel's status before it reads it, with the Type of status wanted. The
+// mock's copy of a panel is never stale, so there is nothing to fetch: the
+// reply only carries the device's current Version.
+func HandleRequestPoll(w http.ResponseWriter, reqData *inimcloud.Request) {
+	v := validateParams(reqData.Params)
+	deviceId := v.Int("DeviceId")
+	v.OptionalInt("Type")
+	if !v.Check(w) {
+		return
+	}
+	stateMu.Lock()
+	defer stateMu.Unlock()
+
+	if _, found := store.Device(deviceId); !found {
+		WriteStatus(w, ErrUnknownDevice, "Device not found")
+		return
+	}
+	WriteJson(w, map[string]any{
+		"DeviceId": deviceId,
+		"Version":  store.Version(deviceId),
+	})
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/lacherogwu/ha-inim_cloud/mockapi/inimcloud"
+)
+
+func pollHint(t *testing.T, h http.Handler) string {
+	t.Helper()
+	rec, _ := callApi(t, h, inimcloud.Request{Method: inimcloud.MethodGetDevicesExtended})
+	return rec.Header().Get("X-Poll-Interval")
+}
+
+func TestPollIntervalBacksOffAndResets(t *testing.T) {
+	setFlag(t, pollMin, 5*time.Second)
+	setFlag(t, pollMax, 20*time.Second)
+	c := useFakeClock(t)
+	h := newTestMux(t)
+	mustCall(t, h, inimcloud.MethodActivateScenario, activate(2), nil)
+
+	want := []string{"5", "10", "20", "20"}
+	for i, w := range want {
+		if got := pollHint(t, h); got != w {
+			t.Errorf("read %d hint = %q, want %q", i+1, got, w)
+		}
+	}
+
+	c.Advance(time.Minute)
+	mustCall(t, h, inimcloud.MethodActivateScenario, activate(1), nil)
+	if got := pollHint(t, h); got != "5" {
+		t.Errorf("hint after an activation = %q, want 5", got)
+	}
+}
+
+func TestPollIntervalPerClient(t *testing.T) {
+	setFlag(t, pollMin, 5*time.Second)
+	setFlag(t, pollMax, 20*time.Second)
+	h := newTestMux(t)
+	hintFor := func(clientId string, offset int) string {
+		t.Helper()
+		rec, _ := callApi(t, h, inimcloud.Request{
+			Method:   inimcloud.MethodGetDevicesExtended,
+			ClientId: clientId,
+			Params:   map[string]any{"Offset": offset, "Limit": 1},
+		})
+		return rec.Header().Get("X-Poll-Interval")
+	}
+
+	hintFor("ha-1", 0)
+	hintFor("ha-1", 0)
+	if got := hintFor("ha-2", 0); got != "5" {
+		t.Errorf("first read of another client = %q, want 5", got)
+	}
+	for range 3 {
+		if got := hintFor("ha-1", 1); got != "10" {
+			t.Errorf("hint for a further page = %q, want 10", got)
+		}
+	}
+	if got := hintFor("ha-1", 0); got != "20" {
+		t.Errorf("next first page = %q, want 20", got)
+	}
+}
+
+func TestPollIntervalOnlyOnDeviceReads(t *testing.T) {
+	h := newTestMux(t)
+	rec, _ := callApi(t, h, inimcloud.Request{Method: inimcloud.MethodGetSystemTime})
+	if got := rec.Header().Get("X-Poll-Interval"); got != "" {
+		t.Errorf("GetSystemTime hint = %q, want none", got)
+	}
+}
+
+func TestRequestPoll(t *testing.T) {
+	h := newTestMux(t)
+	mustCall(t, h, inimcloud.MethodActivateScenario, activate(2), nil)
+
+	// The parameters as the integration sends them.
+	data := struct{ DeviceId, Version int }{}
+	mustCall(t, h, inimcloud.MethodRequestPoll, map[string]any{"DeviceId": "545002", "Type": 5}, &data)
+	if data.DeviceId != testDevice || data.Version != getDevice(t, h).Version {
+		t.Errorf("RequestPoll = %+v, want the device's current version", data)
+	}
+	if status := callStatus(t, h, inimcloud.MethodRequestPoll, map[string]any{"DeviceId": 1}); status != int(ErrUnknownDevice) {
+		t.Errorf("RequestPoll of an unknown device = %d, want %d", status, ErrUnknownDevice)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"time"
+)
+
+// The real cloud is eventually consistent: right after ActivateScenario a
+// read may still report the previous scenario. With -propagation-delay set,
+// GetDevicesExtended only reflects a change once the delay has passed.
+var propagationDelay = flag.Duration("propagation-delay", 0, "delay before a scenario change is visible to GetDevicesExtended")
+
+type pendingChange struct {
+	ScenarioId int
+	At         time.Time
+}
+
+type propagation struct {
+	visible int
+	pending []pendingChange
+}
+
+// propagations tracks devices with changes still in flight. It is guarded by
+// stateMu.
+var propagations = map[int]*propagation{}
+
+// RecordPropagation notes a pending scenario change so reads keep serving
+// the previous scenario until it has propagated. The caller must hold
+// stateMu.
+func RecordPropagation(deviceId, scenarioId int) {
+	if *propagationDelay <= 0 {
+		return
+	}
+
+	p, ok := propagations[deviceId]
+	if !ok {
+		p = &propagation{visible: store.ActiveScenario(deviceId)}
+		propagations[deviceId] = p
+	}
+	p.pending = append(p.pending, pendingChange{ScenarioId: scenarioId, At: clock.Now()})
+}
+
+// VisibleScenario returns the active scenario as reads currently see it. The
+// caller must hold stateMu.
+func VisibleScenario(deviceId int) int {
+	p, ok := propagations[deviceId]
+	if !ok {
+		return store.ActiveScenario(deviceId)
+	}
+
+	now := clock.Now()
+	for len(p.pending) > 0 && now.Sub(p.pending[0].At) >= *propagationDelay {
+		p.visible = p.pending[0].ScenarioId
+		p.pending = p.pending[1:]
+	}
+	if len(p.pending) == 0 {
+		delete(propagations, deviceId)
+	}
+	return p.visible
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lacherogwu/ha-inim_cloud/mockapi/inimcloud"
+)
+
+func TestPropagationDelaysReads(t *testing.T) {
+	fake := useFakeClock(t)
+	setFlag(t, propagationDelay, 2*time.Second)
+	h := newTestMux(t)
+
+	mustCall(t, h, inimcloud.MethodActivateScenario, activate(2), nil)
+	if got := getDevice(t, h).ActiveScenario; got != 1 {
+		t.Errorf("scenario right after activation = %d, want the previous 1", got)
+	}
+	fake.Advance(time.Second)
+	mustCall(t, h, inimcloud.MethodActivateScenario, activate(0), nil)
+
+	fake.Advance(time.Second)
+	if got := getDevice(t, h).ActiveScenario; got != 2 {
+		t.Errorf("scenario once the first change propagated = %d, want 2", got)
+	}
+	fake.Advance(time.Second)
+	if got := getDevice(t, h).ActiveScenario; got != 0 {
+		t.Errorf("scenario once both changes propagated = %d, want 0", got)
+	}
+}
+
+func TestPropagationDisabledByDefault(t *testing.T) {
+	useFakeClock(t)
+	h := newTestMux(t)
+	mustCall(t, h, inimcloud.MethodActivateScenario, activate(2), nil)
+	if got := getDevice(t, h).ActiveScenario; got != 2 {
+		t.Errorf("scenario = %d, want 2 without -propagation-delay", got)
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/lacherogwu/ha-inim_cloud/mockapi/inimcloud"
+)
+
+// JSON-RPC 2.0 error codes from the specification. Failures reported by the
+// API itself through a non-zero envelope Status keep that Status as their
+// code.
+const (
+	RpcParseError     = -32700
+	RpcInvalidRequest = -32600
+	RpcMethodNotFound = -32601
+	RpcInvalidParams  = -32602
+	RpcInternalError  = -32603
+)
+
+type RpcRequest struct {
+	JsonRpc string           `json:"jsonrpc"`
+	Method  inimcloud.Method `json:"method"`
+	Params  map[string]any   `json:"params"`
+	Id      json.RawMessage  `json:"id,omitempty"`
+}
+
+type RpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    any    `json:"data,omitempty"`
+}
+
+type RpcResponse struct {
+	JsonRpc string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *RpcError       `json:"error,omitempty"`
+	Id      json.RawMessage `json:"id"`
+}
+
+// HandleRpc serves the API as JSON-RPC 2.0 on /rpc, single calls and
+// batches alike. Each call goes through the regular dispatch and its
+// envelope is translated into a result or error object. The session token,
+// if any, is sent as a bearer token. A single call carries the
+// X-Poll-Interval hint of GetDevicesExtended; a batch reply has no room for
+// per-call headers and drops it.
+func HandleRpc(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if !ValidSignature(body, r.Header.Get("X-Signature")) {
+		WriteError(w, http.StatusUnauthorized, "Invalid signature")
+		return
+	}
+
+	token, _ := BearerToken(r)
+	TrackSession(token, r)
+
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(body, &batch); err != nil {
+			writeRpc(w, rpcFailure(nil, RpcParseError, "Parse error"))
+			return
+		}
+		if len(batch) == 0 {
+			writeRpc(w, rpcFailure(nil, RpcInvalidRequest, "Invalid Request"))
+			return
+		}
+
+		responses := []RpcResponse{}
+		for _, raw := range batch {
+			if res, ok := callRpc(raw, token, nil); ok {
+				responses = append(responses, res)
+			}
+		}
+		if len(responses) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		writeRpc(w, responses)
+		return
+	}
+
+	res, ok := callRpc(body, token, w.Header())
+	if !ok {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	writeRpc(w, res)
+}
+
+// callRpc runs one call, copying the headers meant for the client to
+// header unless it is nil. It reports false for notifications, which get no
+// response.
+func callRpc(raw json.RawMessage, token string, header http.Header) (RpcResponse, bool) {
+	if !json.Valid(raw) {
+		return rpcFailure(nil, RpcParseError, "Parse error"), true
+	}
+	req := RpcRequest{}
+	if err := json.Unmarshal(raw, &req); err != nil {
+		return rpcFailure(nil, RpcInvalidRequest, "Invalid Request"), true
+	}
+	if req.JsonRpc != "2.0" || req.Method == "" {
+		return rpcFailure(req.Id, RpcInvalidRequest, "Invalid Request"), true
+	}
+
+	res := rpcDispatch(req, token, header)
+	return res, req.Id != nil
+}
+
+func rpcDispatch(req RpcRequest, token string, header http.Header) RpcResponse {
+	if _, ok := LookupMethod(req.Method); !ok {
+		return rpcFailure(req.Id, RpcMethodNotFound, "Method not found")
+	}
+
+	rec := &rpcRecorder{header: http.Header{}, code: http.StatusOK}
+	Dispatch(rec, &inimcloud.Request{Method: req.Method, Token: token, Params: req.Params})
+	if interval := rec.header.Get("X-Poll-Interval"); interval != "" && header != nil {
+		header.Set("X-Poll-Interval", interval)
+	}
+
+	envelope := map[string]json.RawMessage{}
+	decodeErr := json.Unmarshal(rec.body.Bytes(), &envelope)
+	if decodeErr == nil {
+		var status int
+		json.Unmarshal(envelope[*statusField], &status)
+		if status != 0 {
+			var message string
+			json.Unmarshal(envelope["ErrMsg"], &message)
+			return rpcFailure(req.Id, status, message)
+		}
+	}
+
+	if rec.code != http.StatusOK {
+		errorResponse := struct {
+			Error  string                 `json:"error"`
+			Fields []inimcloud.FieldError `json:"fields"`
+		}{}
+		json.Unmarshal(rec.body.Bytes(), &errorResponse)
+
+		code := RpcInternalError
+		if rec.code == http.StatusBadRequest {
+			code = RpcInvalidParams
+		}
+		res := rpcFailure(req.Id, code, errorResponse.Error)
+		if errorResponse.Fields != nil {
+			res.Error.Data = map[string]any{"fields": errorResponse.Fields}
+		}
+		return res
+	}
+
+	// A reply carries a result or an error, never neither.
+	result := envelope[*dataField]
+	if decodeErr != nil || result == nil || string(result) == "null" {
+		return rpcFailure(req.Id, RpcInternalError, "Invalid response")
+	}
+	return RpcResponse{JsonRpc: "2.0", Result: result, Id: rpcId(req.Id)}
+}
+
+// rpcRecorder keeps the reply Dispatch writes for one call.
+type rpcRecorder struct {
+	header      http.Header
+	code        int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (r *rpcRecorder) Header() http.Header {
+	return r.header
+}
+
+func (r *rpcRecorder) WriteHeader(code int) {
+	if !r.wroteHeader {
+		r.code, r.wroteHeader = code, true
+	}
+}
+
+func (r *rpcRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	return r.body.Write(b)
+}
+
+func rpcFailure(id json.RawMessage, code int, message string) RpcResponse {
+	return RpcResponse{
+		JsonRpc: "2.0",
+		Error:   &RpcError{Code: code, Message: message},
+		Id:      rpcId(id),
+	}
+}
+
+// rpcId echoes the request id, which must be null when it is unknown.
+func rpcId(id json.RawMessage) json.RawMessage {
+	if id == nil {
+		return json.RawMessage("null")
+	}
+	return id
+}
+
+func writeRpc(w http.ResponseWriter, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
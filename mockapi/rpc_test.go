@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lacherogwu/ha-inim_cloud/mockapi/inimcloud"
+)
+
+func callRpcOver(t *testing.T, h http.Handler, body, token string) *httptest.ResponseRecorder {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodPost, "/rpc", strings.NewReader(body))
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	return rec
+}
+
+func decodeRpc(t *testing.T, rec *httptest.ResponseRecorder, v any) {
+	t.Helper()
+	if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+		t.Fatalf("invalid JSON-RPC reply %q: %v", rec.Body, err)
+	}
+}
+
+func TestRpcSingleCall(t *testing.T) {
+	h := newTestMux(t)
+	rec := callRpcOver(t, h, `{"jsonrpc":"2.0","method":"GetDevicesExtended","id":7}`, "")
+	res := struct {
+		JsonRpc string
+		Result  struct{ Devices []struct{ DeviceId int } }
+		Id      int
+	}{}
+	decodeRpc(t, rec, &res)
+	if res.JsonRpc != "2.0" || res.Id != 7 || len(res.Result.Devices) != 1 {
+		t.Errorf("reply = %s", rec.Body)
+	}
+	if rec.Header().Get("X-Poll-Interval") == "" {
+		t.Error("single GetDevicesExtended call lost the X-Poll-Interval hint")
+	}
+}
+
+func TestRpcErrors(t *testing.T) {
+	h := newTestMux(t)
+	tests := []struct {
+		name string
+		body string
+		code int
+	}{
+		{"parse error", `{"jsonrpc":`, RpcParseError},
+		{"not an object", `5`, RpcInvalidRequest},
+		{"missing version", `{"method":"GetSystemTime","id":1}`, RpcInvalidRequest},
+		{"unknown method", `{"jsonrpc":"2.0","method":"Nope","id":1}`, RpcMethodNotFound},
+		{"invalid params", `{"jsonrpc":"2.0","method":"ActivateScenario","params":{},"id":1}`, RpcInvalidParams},
+		{"api error", `{"jsonrpc":"2.0","method":"ActivateScenario","params":{"DeviceId":545002,"ScenarioId":9},"id":1}`, int(ErrUnknownScenario)},
+	}
+	for _, tt := range tests {
+		res := RpcResponse{}
+		decodeRpc(t, callRpcOver(t, h, tt.body, ""), &res)
+		if res.Error == nil || res.Error.Code != tt.code {
+			t.Errorf("%s: error = %+v, want code %d", tt.name, res.Error, tt.code)
+		}
+	}
+}
+
+func TestRpcMalformedReplyIsAnError(t *testing.T) {
+	h := newTestMux(t)
+	post(t, h, "/admin/faults", `{"Method":"GetSystemTime","MalformedRate":1}`)
+
+	res := map[string]json.RawMessage{}
+	decodeRpc(t, callRpcOver(t, h, `{"jsonrpc":"2.0","method":"GetSystemTime","id":1}`, ""), &res)
+	rpcErr := RpcError{}
+	if err := json.Unmarshal(res["error"], &rpcErr); err != nil || rpcErr.Code != RpcInternalError {
+		t.Errorf("reply to a malformed envelope = %v, want error %d", res, RpcInternalError)
+	}
+	if _, ok := res["result"]; ok {
+		t.Errorf("reply carries both a result and an error: %v", res)
+	}
+}
+
+func TestRpcInvalidParamsCarryFields(t *testing.T) {
+	h := newTestMux(t)
+	res := struct {
+		Error struct {
+			Data struct{ Fields []inimcloud.FieldError }
+		}
+	}{}
+	decodeRpc(t, callRpcOver(t, h, `{"jsonrpc":"2.0","method":"ActivateScenario","params":{"DeviceId":545002},"id":1}`, ""), &res)
+	if len(res.Error.Data.Fields) != 1 || res.Error.Data.Fields[0].Field != "ScenarioId" {
+		t.Errorf("fields = %+v", res.Error.Data.Fields)
+	}
+}
+
+func TestRpcBatch(t *testing.T) {
+	h := newTestMux(t)
+	rec := callRpcOver(t, h, `[
+		{"jsonrpc":"2.0","method":"GetSystemTime","id":1},
+		1,
+		{"jsonrpc":"2.0","method":"GetSystemTime"},
+		{"jsonrpc":"2.0","method":"Nope","id":2}
+	]`, "")
+	batch := []RpcResponse{}
+	decodeRpc(t, rec, &batch)
+	if len(batch) != 3 {
+		t.Fatalf("batch = %s, want 3 replies without the notification", rec.Body)
+	}
+	if batch[0].Error != nil || string(batch[0].Id) != "1" {
+		t.Errorf("first reply = %+v", batch[0])
+	}
+	if batch[1].Error == nil || batch[1].Error.Code != RpcInvalidRequest || string(batch[1].Id) != "null" {
+		t.Errorf("non-object entry = %+v, want -32600 with a null id", batch[1])
+	}
+	if batch[2].Error == nil || batch[2].Error.Code != RpcMethodNotFound {
+		t.Errorf("unknown method = %+v", batch[2])
+	}
+
+	res := RpcResponse{}
+	decodeRpc(t, callRpcOver(t, h, `[]`, ""), &res)
+	if res.Error == nil || res.Error.Code != RpcInvalidRequest {
+		t.Errorf("empty batch = %+v", res.Error)
+	}
+}
+
+func TestRpcNotificationsGetNoReply(t *testing.T) {
+	h := newTestMux(t)
+	for _, body := range []string{
+		`{"jsonrpc":"2.0","method":"GetSystemTime"}`,
+		`[{"jsonrpc":"2.0","method":"GetSystemTime"}]`,
+	} {
+		if rec := callRpcOver(t, h, body, ""); rec.Code != http.StatusNoContent || rec.Body.Len() != 0 {
+			t.Errorf("%s = %d %q, want 204", body, rec.Code, rec.Body)
+		}
+	}
+}
+
+func TestRpcBearerToken(t *testing.T) {
+	setFlag(t, requireToken, true)
+	h := newTestMux(t)
+	session := NewSession("ha-1")
+
+	call := `{"jsonrpc":"2.0","method":"GetSystemTime","id":1}`
+	res := RpcResponse{}
+	decodeRpc(t, callRpcOver(t, h, call, ""), &res)
+	if res.Error == nil || res.Error.Code != int(ErrInvalidToken) {
+		t.Errorf("call without a token = %+v", res.Error)
+	}
+	res = RpcResponse{}
+	decodeRpc(t, callRpcOver(t, h, call, session.Token), &res)
+	if res.Error != nil {
+		t.Errorf("call with a bearer token = %+v", res.Error)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"net"
+	"sync"
+	"sync/atomic"
+)
+
+// Connection handling of the HTTP server, to reproduce connection
+// exhaustion. Past -max-conns open connections, new ones are accepted and
+// closed straight away.
+var (
+	readTimeout  = flag.Duration("read-timeout", 0, "http.Server ReadTimeout (0 means none)")
+	writeTimeout = flag.Duration("write-timeout", 0, "http.Server WriteTimeout (0 means none)")
+	idleTimeout  = flag.Duration("idle-timeout", 0, "http.Server IdleTimeout (0 means none)")
+	maxConns     = flag.Int("max-conns", 0, "maximum number of open connections (0 means unlimited)")
+)
+
+type limitListener struct {
+	net.Listener
+	max    int64
+	active atomic.Int64
+}
+
+// LimitListener refuses connections on l beyond max open at once.
+func LimitListener(l net.Listener, max int) net.Listener {
+	return &limitListener{Listener: l, max: int64(max)}
+}
+
+func (l *limitListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		if l.active.Add(1) > l.max {
+			l.active.Add(-1)
+			conn.Close()
+			continue
+		}
+		return &limitConn{Conn: conn, release: func() { l.active.Add(-1) }}, nil
+	}
+}
+
+type limitConn struct {
+	net.Conn
+	once    sync.Once
+	release func()
+}
+
+func (c *limitConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(c.release)
+	return err
+}
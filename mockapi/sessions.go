@@ -0,0 +1,246 @@
+package main
+
+import (
+	"crypto/rand"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lacherogwu/ha-inim_cloud/mockapi/inimcloud"
+)
+
+// RegisterClient and Authenticate issue a fresh token per session, valid for
+// -token-ttl. With -require-token, any call but those of -public-methods
+// must carry a live token; missing, unknown and expired tokens are refused
+// with ErrInvalidToken and the ErrMsg the integration looks for. The token
+// is the request's Token, or that of an Authorization: Bearer header, which
+// takes precedence.
+var (
+	tokenTTL     = flag.Duration("token-ttl", time.Hour, "lifetime of the tokens issued by RegisterClient and Authenticate")
+	requireToken = flag.Bool("require-token", false, "refuse calls without a valid, unexpired token")
+
+	publicMethodList = flag.String("public-methods", "RegisterClient,GetCapabilities", "comma-separated methods -require-token lets through without a token")
+)
+
+// publicMethods can be called without a token. It is only replaced at
+// startup, or by tests.
+var publicMethods = map[inimcloud.Method]bool{
+	inimcloud.MethodRegisterClient:  true,
+	inimcloud.MethodGetCapabilities: true,
+}
+
+// SetPublicMethods replaces the methods that can be called without a token.
+func SetPublicMethods(methods []inimcloud.Method) {
+	publicMethods = map[inimcloud.Method]bool{}
+	for _, method := range methods {
+		publicMethods[method] = true
+	}
+}
+
+// BearerToken returns the token of the request's Authorization: Bearer
+// header, if any.
+func BearerToken(r *http.Request) (string, bool) {
+	return strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+}
+
+type Session struct {
+	// Id names the session without revealing its token, for the history.
+	Id        string    `json:"Id"`
+	Token     string    `json:"Token"`
+	ClientId  string    `json:"ClientId"`
+	Account   string    `json:"Account,omitempty"`
+	IssuedAt  time.Time `json:"IssuedAt"`
+	ExpiresAt time.Time `json:"ExpiresAt"`
+	// Requests counts the HTTP requests made with the token, and ClientIp
+	// is the address the last one came from.
+	Requests int    `json:"Requests"`
+	ClientIp string `json:"ClientIp"`
+}
+
+// sessionsMu guards the sessions in the store. When both are needed,
+// stateMu is taken first.
+var sessionsMu sync.Mutex
+
+// -session-policy decides what RegisterClient does for a client that still
+// holds a live token, as when the official app logs in with the same
+// ClientId: allow-multiple issues another token, replace issues one and
+// ends the client's other sessions, and reject refuses the login with
+// ErrSessionActive. Clients registering without a ClientId can't be told
+// apart and always get a new session. Authenticate only renews the token it
+// is given, so the policy doesn't apply to it.
+const (
+	SessionsAllowMultiple = "allow-multiple"
+	SessionsReplace       = "replace"
+	SessionsReject        = "reject"
+)
+
+var sessionPolicy = flag.String("session-policy", SessionsAllowMultiple, "what RegisterClient does for a client with a live session: allow-multiple, replace or reject")
+
+// ValidSessionPolicy reports whether -session-policy names a policy.
+func ValidSessionPolicy(policy string) bool {
+	return policy == SessionsAllowMultiple || policy == SessionsReplace || policy == SessionsReject
+}
+
+// StartSession issues a token for a client of account under
+// -session-policy. It reports false when the policy refuses the client
+// another session.
+func StartSession(clientId, account string) (Session, bool) {
+	sessionsMu.Lock()
+	defer sessionsMu.Unlock()
+
+	if clientId != "" && *sessionPolicy != SessionsAllowMultiple {
+		for _, other := range store.Sessions() {
+			if session, ok := liveSession(other.Token); !ok || session.ClientId != clientId {
+				continue
+			}
+			if *sessionPolicy == SessionsReject {
+				return Session{}, false
+			}
+			store.DeleteSession(other.Token)
+		}
+	}
+	return newSession(clientId, account), true
+}
+
+// NewSession issues a token for a client, whatever its other sessions.
+func NewSession(clientId string) Session {
+	sessionsMu.Lock()
+	defer sessionsMu.Unlock()
+	return newSession(clientId, "")
+}
+
+// newSession issues a token. The caller must hold sessionsMu.
+func newSession(clientId, account string) Session {
+	b := make([]byte, 16)
+	rand.Read(b)
+	id := make([]byte, 8)
+	rand.Read(id)
+	session := Session{
+		Id:        fmt.Sprintf("%x", id),
+		Token:     fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]),
+		ClientId:  clientId,
+		Account:   account,
+		IssuedAt:  clock.Now(),
+		ExpiresAt: clock.Now().Add(*tokenTTL),
+	}
+	store.PutSession(session)
+	tokensIssued.Add(1)
+	return session
+}
+
+// RenewSession extends a live session by another TTL. It reports false when
+// the token is unknown or has expired.
+func RenewSession(token string) (Session, bool) {
+	sessionsMu.Lock()
+	defer sessionsMu.Unlock()
+
+	session, ok := liveSession(token)
+	if !ok {
+		return Session{}, false
+	}
+	session.ExpiresAt = clock.Now().Add(*tokenTTL)
+	store.PutSession(session)
+	return session, true
+}
+
+// LookupSession returns the live session token belongs to.
+func LookupSession(token string) (Session, bool) {
+	sessionsMu.Lock()
+	defer sessionsMu.Unlock()
+
+	return liveSession(token)
+}
+
+// TrackSession counts a request made with token, if it is live, and notes
+// the address it came from. A JSON-RPC batch counts as one request.
+func TrackSession(token string, r *http.Request) {
+	sessionsMu.Lock()
+	defer sessionsMu.Unlock()
+
+	session, ok := liveSession(token)
+	if !ok {
+		return
+	}
+	session.Requests++
+	session.ClientIp = r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		session.ClientIp = host
+	}
+	store.PutSession(session)
+}
+
+// ValidToken reports whether token belongs to a live session.
+func ValidToken(token string) bool {
+	sessionsMu.Lock()
+	defer sessionsMu.Unlock()
+
+	_, ok := liveSession(token)
+	return ok
+}
+
+// liveSession looks up an unexpired session, forgetting it once expired. The
+// caller must hold sessionsMu.
+func liveSession(token string) (Session, bool) {
+	session, ok := store.Session(token)
+	if !ok {
+		return Session{}, false
+	}
+	if !clock.Now().Before(session.ExpiresAt) {
+		store.DeleteSession(token)
+		return Session{}, false
+	}
+	return session, true
+}
+
+// SnapshotSessions returns the live sessions, for /admin/snapshot.
+func SnapshotSessions() []Session {
+	sessionsMu.Lock()
+	defer sessionsMu.Unlock()
+
+	list := []Session{}
+	for _, session := range store.Sessions() {
+		if session, ok := liveSession(session.Token); ok {
+			list = append(list, session)
+		}
+	}
+	slices.SortFunc(list, func(a, b Session) int { return a.ExpiresAt.Compare(b.ExpiresAt) })
+	return list
+}
+
+// RestoreSessions replaces every session with list.
+func RestoreSessions(list []Session) {
+	sessionsMu.Lock()
+	defer sessionsMu.Unlock()
+
+	store.RestoreSessions(list)
+}
+
+func writeSession(w http.ResponseWriter, session Session) {
+	WriteJson(w, inimcloud.AuthResponse{
+		Token: session.Token,
+		TTL:   int(session.ExpiresAt.Sub(clock.Now()).Round(time.Second).Seconds()),
+	})
+}
+
+// HandleGetSessionInfo describes the caller's own session, so a client can
+// show the health of its connection and renew before the token runs out.
+func HandleGetSessionInfo(w http.ResponseWriter, reqData *inimcloud.Request) {
+	session, ok := LookupSession(reqData.Token)
+	if !ok {
+		WriteStatus(w, ErrInvalidToken, "Token not valid or expired")
+		return
+	}
+	WriteJson(w, map[string]any{
+		"ClientId":  session.ClientId,
+		"IssuedAt":  session.IssuedAt,
+		"ExpiresAt": session.ExpiresAt,
+		"TTL":       int(session.ExpiresAt.Sub(clock.Now()).Round(time.Second).Seconds()),
+		"Requests":  session.Requests,
+		"ClientIp":  session.ClientIp,
+	})
+}
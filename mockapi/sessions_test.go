@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/lacherogwu/ha-inim_cloud/mockapi/inimcloud"
+)
+
+// login registers a client and returns its token.
+func login(t *testing.T, h http.Handler) string {
+	t.Helper()
+	data := struct {
+		Token string
+		TTL   int
+	}{}
+	mustCall(t, h, inimcloud.MethodRegisterClient, map[string]any{"ClientId": "test"}, &data)
+	if data.TTL != int(tokenTTL.Seconds()) {
+		t.Errorf("TTL = %d, want %s", data.TTL, *tokenTTL)
+	}
+	return data.Token
+}
+
+func TestTokensAreUnique(t *testing.T) {
+	h := newTestMux(t)
+	if first, second := login(t, h), login(t, h); first == second {
+		t.Errorf("two logins got the same token %s", first)
+	}
+}
+
+func TestRequireToken(t *testing.T) {
+	setFlag(t, requireToken, true)
+	setFlag(t, tokenTTL, 30*time.Second)
+	c := useFakeClock(t)
+	h := newTestMux(t)
+	token := login(t, h)
+
+	call := func(token string) int {
+		_, reply := callApi(t, h, inimcloud.Request{Method: inimcloud.MethodGetDevicesExtended, Token: token})
+		return reply.Status
+	}
+	if status := call(token); status != 0 {
+		t.Errorf("live token: Status = %d", status)
+	}
+	for name, token := range map[string]string{"missing": "", "unknown": "nope"} {
+		if status := call(token); status != int(ErrInvalidToken) {
+			t.Errorf("%s token: Status = %d, want %d", name, status, ErrInvalidToken)
+		}
+	}
+
+	c.Advance(30 * time.Second)
+	if status := call(token); status != int(ErrInvalidToken) {
+		t.Errorf("expired token: Status = %d, want %d", status, ErrInvalidToken)
+	}
+	if status := callStatus(t, h, inimcloud.MethodGetCapabilities, nil); status != 0 {
+		t.Errorf("public method without a token: Status = %d", status)
+	}
+}
+
+func TestPublicMethods(t *testing.T) {
+	setFlag(t, requireToken, true)
+	h := newTestMux(t)
+	t.Cleanup(func() { SetPublicMethods(ParseMethods(*publicMethodList)) })
+
+	for _, tt := range []struct {
+		public string
+		want   int
+	}{
+		{"RegisterClient, GetDevicesExtended", 0},
+		{"RegisterClient", int(ErrInvalidToken)},
+	} {
+		SetPublicMethods(ParseMethods(tt.public))
+		if status := callStatus(t, h, inimcloud.MethodGetDevicesExtended, nil); status != tt.want {
+			t.Errorf("-public-methods %q: GetDevicesExtended without a token = %d, want %d", tt.public, status, tt.want)
+		}
+	}
+	if status := callStatus(t, h, inimcloud.MethodGetCapabilities, nil); status != int(ErrInvalidToken) {
+		t.Errorf("GetCapabilities left out of -public-methods = %d, want %d", status, ErrInvalidToken)
+	}
+}
+
+func TestBearerToken(t *testing.T) {
+	setFlag(t, requireToken, true)
+	h := newTestMux(t)
+	token := login(t, h)
+
+	call := func(header, param string) int {
+		body, _ := json.Marshal(inimcloud.Request{Method: inimcloud.MethodGetDevicesExtended, Token: param})
+		r := httptest.NewRequest(http.MethodGet, "/?req="+url.QueryEscape(string(body)), nil)
+		r.Header.Set("Authorization", "Bearer "+header)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		reply := apiReply{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &reply); err != nil {
+			t.Fatalf("invalid reply %q: %v", rec.Body, err)
+		}
+		return reply.Status
+	}
+	if status := call(token, ""); status != 0 {
+		t.Errorf("token in the header only: Status = %d", status)
+	}
+	if status := call(token, "nope"); status != 0 {
+		t.Errorf("valid header over a stale Token: Status = %d", status)
+	}
+	if status := call("nope", token); status != int(ErrInvalidToken) {
+		t.Errorf("stale header over a valid Token: Status = %d, want %d", status, ErrInvalidToken)
+	}
+}
+
+func TestAuthenticateRenewsLiveTokensOnly(t *testing.T) {
+	setFlag(t, requireToken, true)
+	setFlag(t, tokenTTL, 30*time.Second)
+	c := useFakeClock(t)
+	h := newTestMux(t)
+	token := login(t, h)
+
+	c.Advance(20 * time.Second)
+	if _, reply := callApi(t, h, inimcloud.Request{Method: inimcloud.MethodAuthenticate, Token: token}); reply.Status != 0 {
+		t.Fatalf("Authenticate with a live token: Status = %d", reply.Status)
+	}
+	c.Advance(20 * time.Second)
+	if !ValidToken(token) {
+		t.Fatal("renewed token expired at its original time")
+	}
+
+	c.Advance(10 * time.Second)
+	_, reply := callApi(t, h, inimcloud.Request{Method: inimcloud.MethodAuthenticate, Token: token})
+	if reply.Status != int(ErrInvalidToken) || reply.ErrMsg != "Token not valid or expired" {
+		t.Errorf("Authenticate with an expired token = %d %q", reply.Status, reply.ErrMsg)
+	}
+}
+
+func TestSessionPolicy(t *testing.T) {
+	for _, tt := range []struct {
+		policy     string
+		priorValid bool
+		newStatus  int
+	}{
+		{SessionsAllowMultiple, true, 0},
+		{SessionsReplace, false, 0},
+		{SessionsReject, true, int(ErrSessionActive)},
+	} {
+		setFlag(t, sessionPolicy, tt.policy)
+		h := newTestMux(t)
+		prior := login(t, h)
+		other := struct{ Token string }{}
+		mustCall(t, h, inimcloud.MethodRegisterClient, map[string]any{"ClientId": "other"}, &other)
+
+		_, reply := callApi(t, h, inimcloud.Request{Method: inimcloud.MethodRegisterClient, Params: map[string]any{"ClientId": "test"}})
+		if reply.Status != tt.newStatus {
+			t.Errorf("%s: second RegisterClient = %d, want %d", tt.policy, reply.Status, tt.newStatus)
+		}
+		if got := ValidToken(prior); got != tt.priorValid {
+			t.Errorf("%s: prior token valid = %t, want %t", tt.policy, got, tt.priorValid)
+		}
+		if !ValidToken(other.Token) {
+			t.Errorf("%s: another client's token was ended", tt.policy)
+		}
+	}
+}
+
+func TestGetSessionInfo(t *testing.T) {
+	c := useFakeClock(t)
+	h := newTestMux(t)
+	token := login(t, h)
+	c.Advance(10 * time.Minute)
+
+	info := struct {
+		ClientId string
+		IssuedAt time.Time
+		TTL      int
+		Requests int
+		ClientIp string
+	}{}
+	for want := 1; want <= 3; want++ {
+		_, reply := callApi(t, h, inimcloud.Request{Method: inimcloud.MethodGetSessionInfo, Token: token})
+		if err := json.Unmarshal(reply.Data, &info); err != nil || reply.Status != 0 {
+			t.Fatalf("GetSessionInfo = %+v", reply)
+		}
+		if info.Requests != want {
+			t.Errorf("Requests = %d, want %d", info.Requests, want)
+		}
+	}
+	if info.ClientId != "test" || !info.IssuedAt.Equal(c.Now().Add(-10*time.Minute)) || info.TTL != int((*tokenTTL-10*time.Minute).Seconds()) || info.ClientIp != "192.0.2.1" {
+		t.Errorf("session info = %+v", info)
+	}
+	if status := callStatus(t, h, inimcloud.MethodGetSessionInfo, nil); status != int(ErrInvalidToken) {
+		t.Errorf("GetSessionInfo without a token = %d, want %d", status, ErrInvalidToken)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// On SIGINT or SIGTERM the server reports not ready on /readyz, keeps
+// serving for -drain-timeout so it can be taken out of rotation, then shuts
+// down, giving in-flight requests up to the same timeout to finish.
+var drainTimeout = flag.Duration("drain-timeout", 5*time.Second, "time to keep serving after a shutdown signal, and to wait for in-flight requests")
+
+var (
+	ready          atomic.Bool
+	requestsServed atomic.Int64
+	tokensIssued   atomic.Int64
+)
+
+func HandleReadyz(w http.ResponseWriter, r *http.Request) {
+	if !ready.Load() {
+		WriteError(w, http.StatusServiceUnavailable, "Shutting down")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte("{\"ready\":true}\n"))
+}
+
+// CountRequests counts every request handled by next.
+func CountRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestsServed.Add(1)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Shutdown drains and stops server.
+func Shutdown(server *http.Server) {
+	ready.Store(false)
+	fmt.Printf("Shutting down, draining for %s\n", *drainTimeout)
+	time.Sleep(*drainTimeout)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), *drainTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		fmt.Printf("Shutdown did not complete: %v\n", err)
+	}
+
+	// The mock keeps its state in memory only, so there is nothing to flush.
+	fmt.Printf("Served %d requests, issued %d tokens\n", requestsServed.Load(), tokensIssued.Load())
+}
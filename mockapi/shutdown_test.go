@@ -0,0 +1,55 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func readyzStatus(t *testing.T, url string) int {
+	t.Helper()
+	res, err := http.Get(url + "/readyz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	return res.StatusCode
+}
+
+func TestReadyzDuringDrain(t *testing.T) {
+	setFlag(t, drainTimeout, 300*time.Millisecond)
+	ready.Store(true)
+	t.Cleanup(func() { ready.Store(false) })
+
+	ts := httptest.NewServer(CountRequests(newTestMux(t)))
+	defer ts.Close()
+	if status := readyzStatus(t, ts.URL); status != http.StatusOK {
+		t.Fatalf("/readyz before shutdown = %d, want 200", status)
+	}
+	served := requestsServed.Load()
+
+	done := make(chan struct{})
+	go func() {
+		Shutdown(ts.Config)
+		close(done)
+	}()
+	for ready.Load() {
+		time.Sleep(time.Millisecond)
+	}
+	if status := readyzStatus(t, ts.URL); status != http.StatusServiceUnavailable {
+		t.Errorf("/readyz while draining = %d, want 503", status)
+	}
+	if got := requestsServed.Load(); got != served+1 {
+		t.Errorf("requests served while draining = %d, want %d", got, served+1)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Shutdown did not return")
+	}
+	if _, err := http.Get(ts.URL + "/readyz"); err == nil {
+		t.Error("server still serving after Shutdown")
+	}
+}
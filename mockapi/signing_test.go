@@ -0,0 +1,79 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+const testSecret = "s3cret"
+
+func sign(payload string) string {
+	mac := hmac.New(sha256.New, []byte(testSecret))
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func signedApiCall(h http.Handler, payload, signature string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, "/?req="+url.QueryEscape(payload), nil)
+	if signature != "" {
+		r.Header.Set("X-Signature", signature)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	return rec
+}
+
+func TestSignatureVerified(t *testing.T) {
+	setFlag(t, signingSecret, testSecret)
+	h := newTestMux(t)
+	payload := `{"Method":"GetSystemTime"}`
+
+	tests := []struct {
+		name      string
+		signature string
+		want      int
+	}{
+		{"valid", sign(payload), http.StatusOK},
+		{"missing", "", http.StatusUnauthorized},
+		{"tampered", sign(`{"Method":"GetDevicesExtended"}`), http.StatusUnauthorized},
+		{"other secret", strings.Repeat("00", sha256.Size), http.StatusUnauthorized},
+		{"not hex", "zz", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		if rec := signedApiCall(h, payload, tt.signature); rec.Code != tt.want {
+			t.Errorf("%s signature = %d %s, want %d", tt.name, rec.Code, rec.Body, tt.want)
+		}
+	}
+}
+
+func TestSignatureCoversRpcBody(t *testing.T) {
+	setFlag(t, signingSecret, testSecret)
+	h := newTestMux(t)
+	body := `{"jsonrpc":"2.0","id":1,"method":"GetSystemTime"}`
+
+	for signature, want := range map[string]int{
+		sign(body):                               http.StatusOK,
+		sign(strings.Replace(body, "1", "2", 1)): http.StatusUnauthorized,
+	} {
+		r := httptest.NewRequest(http.MethodPost, "/rpc", strings.NewReader(body))
+		r.Header.Set("X-Signature", signature)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		if rec.Code != want {
+			t.Errorf("/rpc signed %s = %d %s, want %d", signature, rec.Code, rec.Body, want)
+		}
+	}
+}
+
+func TestSigningDisabledByDefault(t *testing.T) {
+	h := newTestMux(t)
+	if rec := signedApiCall(h, `{"Method":"GetSystemTime"}`, "junk"); rec.Code != http.StatusOK {
+		t.Errorf("unsigned call without -signing-secret = %d %s", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// By default the envelope Status is the ErrorCode value itself. A status map
+// renumbers errors to match what the real cloud is observed to send, for
+// example:
+//
+//	{"ErrUnknownDevice": 103, "ErrMaintenance": 503}
+//
+// Errors missing from the map keep their default value.
+var statusMapFile = flag.String("status-map", "", "JSON file mapping error names to the Status values to report")
+
+var statusMap = map[ErrorCode]int{}
+
+func LoadStatusMap(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	byName := map[string]int{}
+	if err := json.Unmarshal(data, &byName); err != nil {
+		return err
+	}
+	mapping := make(map[ErrorCode]int, len(byName))
+	for name, status := range byName {
+		code, ok := errorNames[name]
+		if !ok {
+			return fmt.Errorf("unknown error %q", name)
+		}
+		if status == 0 {
+			return fmt.Errorf("%s: Status 0 means success", name)
+		}
+		mapping[code] = status
+	}
+	statusMap = mapping
+	return nil
+}
+
+// WireStatus is the Status reported for code.
+func WireStatus(code ErrorCode) int {
+	if status, ok := statusMap[code]; ok {
+		return status
+	}
+	return int(code)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lacherogwu/ha-inim_cloud/mockapi/inimcloud"
+)
+
+func writeStatusMap(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "status.json")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestStatusMapRenumbersErrors(t *testing.T) {
+	h := newTestMux(t)
+	if err := LoadStatusMap(writeStatusMap(t, `{"ErrUnknownScenario": 105}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	if status := callStatus(t, h, inimcloud.MethodActivateScenario, activate(9)); status != 105 {
+		t.Errorf("unknown scenario: Status = %d, want the mapped 105", status)
+	}
+	params := map[string]any{"DeviceId": 1, "ScenarioId": 1}
+	if status := callStatus(t, h, inimcloud.MethodActivateScenario, params); status != int(ErrUnknownDevice) {
+		t.Errorf("unknown device: Status = %d, want the default %d", status, ErrUnknownDevice)
+	}
+	if status := callStatus(t, h, inimcloud.MethodActivateScenario, activate(2)); status != 0 {
+		t.Errorf("success: Status = %d, want 0", status)
+	}
+}
+
+func TestLoadStatusMapErrors(t *testing.T) {
+	resetState()
+	for _, content := range []string{
+		`{"ErrNoSuchThing": 1}`,
+		`{"ErrUnknownDevice": 0}`,
+		`[1, 2]`,
+	} {
+		if err := LoadStatusMap(writeStatusMap(t, content)); err == nil {
+			t.Errorf("LoadStatusMap(%s) succeeded", content)
+		}
+	}
+	if err := LoadStatusMap(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("LoadStatusMap of a missing file succeeded")
+	}
+	if len(statusMap) != 0 {
+		t.Errorf("failed loads changed the map: %v", statusMap)
+	}
+}
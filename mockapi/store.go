@@ -0,0 +1,439 @@
+package main
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/lacherogwu/ha-inim_cloud/mockapi/inimcloud"
+)
+
+// Store holds the devices and everything the mock keeps about them, the
+// event log, the activation commands and the sessions, so handlers don't
+// depend on how that state is kept. Simulation settings such as fault
+// rules, outages and behavior scripts stay in the process. Implementations
+// need not be safe for concurrent use: every access happens with stateMu
+// held, except for the token methods, which are called with sessionsMu held
+// as tokens are looked up both with and without stateMu.
+type Store interface {
+	Devices() []Device
+	Device(deviceId int) (Device, bool)
+	ActiveScenario(deviceId int) int
+	// SetActiveScenario changes the device's scenario, bumping its version
+	// and recording the time of the change.
+	SetActiveScenario(deviceId, scenarioId int)
+	// MarkChanged bumps the device's version and records the time, for
+	// changes to state other than the scenario.
+	MarkChanged(deviceId int)
+	Version(deviceId int) int
+	ChangedAt(deviceId int) time.Time
+
+	ZoneOpen(deviceId, zoneId int) bool
+	SetZoneOpen(deviceId, zoneId int, open bool)
+	Alarm(deviceId, areaId int) bool
+	SetAlarm(deviceId, areaId int, active bool)
+
+	// ExitDelay returns the device's running arming countdown, if any.
+	ExitDelay(deviceId int) (inimcloud.ExitDelayView, bool)
+	SetExitDelay(deviceId int, pending inimcloud.ExitDelayView)
+	ClearExitDelay(deviceId int)
+
+	// LastCommandAt is when the device last accepted an activation, for the
+	// command cooldown.
+	LastCommandAt(deviceId int) time.Time
+	SetLastCommandAt(deviceId int, at time.Time)
+
+	// Delays returns the device's delays, unless they were never set.
+	Delays(deviceId int) (Delays, bool)
+	SetDelays(deviceId int, d Delays)
+	// NotificationSettings returns the settings changed for the device. It
+	// must not be modified.
+	NotificationSettings(deviceId int) map[NotificationEvent]bool
+	SetNotificationSetting(deviceId int, event NotificationEvent, enabled bool)
+
+	// History returns the device's scenario changes, oldest first. It must
+	// not be modified.
+	History(deviceId int) []HistoryEntry
+	// AppendHistory adds a change, keeping only the last limit.
+	AppendHistory(deviceId int, entry HistoryEntry, limit int)
+
+	// Faults returns the device's active faults. It must not be modified.
+	Faults(deviceId int) []Fault
+	// AddFault raises a fault, assigning it the next FaultId.
+	AddFault(deviceId int, fault Fault) Fault
+	// RemoveFault reports false when the device has no such fault.
+	RemoveFault(deviceId, faultId int) bool
+
+	// FirmwareVersion returns the version a completed update installed.
+	FirmwareVersion(deviceId int) (string, bool)
+	SetFirmwareVersion(deviceId int, version string)
+	// FirmwareUpdate returns the device's update in progress, if any.
+	FirmwareUpdate(deviceId int) (FirmwareUpdate, bool)
+	SetFirmwareUpdate(deviceId int, update FirmwareUpdate)
+	ClearFirmwareUpdate(deviceId int)
+
+	// AddCommand registers a command, assigning it the next CommandId.
+	AddCommand(cmd Command) Command
+	Command(commandId string) (Command, bool)
+	SetCommandStatus(commandId string, status CommandStatus)
+
+	// AppendEvent adds an event to the log, keeping only the last limit.
+	AppendEvent(event Event, limit int)
+	// Events returns the log, oldest first. It must not be modified.
+	Events() []Event
+
+	// Session returns the session of token, expired or not.
+	Session(token string) (Session, bool)
+	// PutSession adds or replaces the session of session.Token.
+	PutSession(session Session)
+	DeleteSession(token string)
+	// Sessions returns every session, in no particular order.
+	Sessions() []Session
+	// RestoreSessions replaces every session with list.
+	RestoreSessions(list []Session)
+
+	// Snapshot captures everything but the running exit delays, which
+	// Restore cancels, and the sessions, which are captured separately
+	// under sessionsMu.
+	Snapshot() StoreState
+	Restore(state StoreState)
+}
+
+// StoreState is the mutable part of a store, as captured in a snapshot.
+type StoreState struct {
+	ActiveScenario map[int]int       `json:"ActiveScenario"`
+	Versions       map[int]int       `json:"Versions"`
+	ChangedAt      map[int]time.Time `json:"ChangedAt"`
+	// OpenZones and Alarms are keyed by device, then zone or area.
+	OpenZones     map[int]map[int]bool               `json:"OpenZones"`
+	Alarms        map[int]map[int]bool               `json:"Alarms"`
+	LastCommandAt map[int]time.Time                  `json:"LastCommandAt"`
+	Delays        map[int]Delays                     `json:"Delays"`
+	Notifications map[int]map[NotificationEvent]bool `json:"Notifications"`
+	History       map[int][]HistoryEntry             `json:"History"`
+	Faults        map[int][]Fault                    `json:"Faults"`
+	NextFaultId   int                                `json:"NextFaultId"`
+	// Firmware holds the versions installed by completed updates.
+	Firmware        map[int]string         `json:"Firmware"`
+	FirmwareUpdates map[int]FirmwareUpdate `json:"FirmwareUpdates"`
+	Commands        []Command              `json:"Commands"`
+	NextCommandId   int                    `json:"NextCommandId"`
+	Events          []Event                `json:"Events"`
+}
+
+// memoryStore is the default Store, backed by maps.
+type memoryStore struct {
+	devices         []Device
+	activeScenario  map[int]int
+	versions        map[int]int
+	changedAt       map[int]time.Time
+	openZones       map[int]map[int]bool
+	alarms          map[int]map[int]bool
+	exitDelays      map[int]inimcloud.ExitDelayView
+	lastCommandAt   map[int]time.Time
+	delays          map[int]Delays
+	notifications   map[int]map[NotificationEvent]bool
+	history         map[int][]HistoryEntry
+	faults          map[int][]Fault
+	nextFaultId     int
+	firmware        map[int]string
+	firmwareUpdates map[int]FirmwareUpdate
+	commands        map[string]Command
+	nextCommandId   int
+	events          []Event
+	sessions        map[string]Session
+}
+
+func NewMemoryStore(devices []Device, activeScenario map[int]int) Store {
+	s := &memoryStore{devices: devices, sessions: map[string]Session{}}
+	s.Restore(StoreState{ActiveScenario: activeScenario})
+	return s
+}
+
+func (s *memoryStore) Devices() []Device {
+	return s.devices
+}
+
+func (s *memoryStore) Device(deviceId int) (Device, bool) {
+	for _, device := range s.devices {
+		if device.DeviceId == deviceId {
+			return device, true
+		}
+	}
+	return Device{}, false
+}
+
+func (s *memoryStore) ActiveScenario(deviceId int) int {
+	return s.activeScenario[deviceId]
+}
+
+func (s *memoryStore) SetActiveScenario(deviceId, scenarioId int) {
+	s.activeScenario[deviceId] = scenarioId
+	s.MarkChanged(deviceId)
+}
+
+func (s *memoryStore) MarkChanged(deviceId int) {
+	s.versions[deviceId]++
+	s.changedAt[deviceId] = clock.Now()
+}
+
+func (s *memoryStore) Version(deviceId int) int {
+	return s.versions[deviceId]
+}
+
+func (s *memoryStore) ChangedAt(deviceId int) time.Time {
+	return s.changedAt[deviceId]
+}
+
+func (s *memoryStore) ZoneOpen(deviceId, zoneId int) bool {
+	return s.openZones[deviceId][zoneId]
+}
+
+func (s *memoryStore) SetZoneOpen(deviceId, zoneId int, open bool) {
+	setNested(s.openZones, deviceId, zoneId, open)
+}
+
+func (s *memoryStore) Alarm(deviceId, areaId int) bool {
+	return s.alarms[deviceId][areaId]
+}
+
+func (s *memoryStore) SetAlarm(deviceId, areaId int, active bool) {
+	setNested(s.alarms, deviceId, areaId, active)
+}
+
+func (s *memoryStore) ExitDelay(deviceId int) (inimcloud.ExitDelayView, bool) {
+	pending, ok := s.exitDelays[deviceId]
+	return pending, ok
+}
+
+func (s *memoryStore) SetExitDelay(deviceId int, pending inimcloud.ExitDelayView) {
+	s.exitDelays[deviceId] = pending
+}
+
+func (s *memoryStore) ClearExitDelay(deviceId int) {
+	delete(s.exitDelays, deviceId)
+}
+
+func (s *memoryStore) LastCommandAt(deviceId int) time.Time {
+	return s.lastCommandAt[deviceId]
+}
+
+func (s *memoryStore) SetLastCommandAt(deviceId int, at time.Time) {
+	s.lastCommandAt[deviceId] = at
+}
+
+func (s *memoryStore) Delays(deviceId int) (Delays, bool) {
+	d, ok := s.delays[deviceId]
+	return d, ok
+}
+
+func (s *memoryStore) SetDelays(deviceId int, d Delays) {
+	s.delays[deviceId] = d
+}
+
+func (s *memoryStore) NotificationSettings(deviceId int) map[NotificationEvent]bool {
+	return s.notifications[deviceId]
+}
+
+func (s *memoryStore) SetNotificationSetting(deviceId int, event NotificationEvent, enabled bool) {
+	if s.notifications[deviceId] == nil {
+		s.notifications[deviceId] = map[NotificationEvent]bool{}
+	}
+	s.notifications[deviceId][event] = enabled
+}
+
+func (s *memoryStore) History(deviceId int) []HistoryEntry {
+	return s.history[deviceId]
+}
+
+func (s *memoryStore) AppendHistory(deviceId int, entry HistoryEntry, limit int) {
+	entries := append(s.history[deviceId], entry)
+	if len(entries) > limit {
+		entries = slices.Clone(entries[len(entries)-limit:])
+	}
+	s.history[deviceId] = entries
+}
+
+func (s *memoryStore) Faults(deviceId int) []Fault {
+	return s.faults[deviceId]
+}
+
+func (s *memoryStore) AddFault(deviceId int, fault Fault) Fault {
+	fault.FaultId = s.nextFaultId
+	s.nextFaultId++
+	s.faults[deviceId] = append(s.faults[deviceId], fault)
+	return fault
+}
+
+func (s *memoryStore) RemoveFault(deviceId, faultId int) bool {
+	i := slices.IndexFunc(s.faults[deviceId], func(f Fault) bool { return f.FaultId == faultId })
+	if i < 0 {
+		return false
+	}
+	s.faults[deviceId] = slices.Delete(slices.Clone(s.faults[deviceId]), i, i+1)
+	return true
+}
+
+func (s *memoryStore) FirmwareVersion(deviceId int) (string, bool) {
+	version, ok := s.firmware[deviceId]
+	return version, ok
+}
+
+func (s *memoryStore) SetFirmwareVersion(deviceId int, version string) {
+	s.firmware[deviceId] = version
+}
+
+func (s *memoryStore) FirmwareUpdate(deviceId int) (FirmwareUpdate, bool) {
+	update, ok := s.firmwareUpdates[deviceId]
+	return update, ok
+}
+
+func (s *memoryStore) SetFirmwareUpdate(deviceId int, update FirmwareUpdate) {
+	s.firmwareUpdates[deviceId] = update
+}
+
+func (s *memoryStore) ClearFirmwareUpdate(deviceId int) {
+	delete(s.firmwareUpdates, deviceId)
+}
+
+func (s *memoryStore) AddCommand(cmd Command) Command {
+	cmd.CommandId = strconv.Itoa(s.nextCommandId)
+	s.nextCommandId++
+	s.commands[cmd.CommandId] = cmd
+	return cmd
+}
+
+func (s *memoryStore) Command(commandId string) (Command, bool) {
+	cmd, ok := s.commands[commandId]
+	return cmd, ok
+}
+
+func (s *memoryStore) SetCommandStatus(commandId string, status CommandStatus) {
+	if cmd, ok := s.commands[commandId]; ok {
+		cmd.Status = status
+		s.commands[commandId] = cmd
+	}
+}
+
+func (s *memoryStore) AppendEvent(event Event, limit int) {
+	s.events = append(s.events, event)
+	if len(s.events) > limit {
+		s.events = slices.Clone(s.events[len(s.events)-limit:])
+	}
+}
+
+func (s *memoryStore) Events() []Event {
+	return s.events
+}
+
+func (s *memoryStore) Session(token string) (Session, bool) {
+	session, ok := s.sessions[token]
+	return session, ok
+}
+
+func (s *memoryStore) PutSession(session Session) {
+	s.sessions[session.Token] = session
+}
+
+func (s *memoryStore) DeleteSession(token string) {
+	delete(s.sessions, token)
+}
+
+func (s *memoryStore) Sessions() []Session {
+	return slices.Collect(maps.Values(s.sessions))
+}
+
+func (s *memoryStore) RestoreSessions(list []Session) {
+	s.sessions = make(map[string]Session, len(list))
+	for _, session := range list {
+		s.sessions[session.Token] = session
+	}
+}
+
+func (s *memoryStore) Snapshot() StoreState {
+	state := StoreState{
+		ActiveScenario:  maps.Clone(s.activeScenario),
+		Versions:        maps.Clone(s.versions),
+		ChangedAt:       maps.Clone(s.changedAt),
+		OpenZones:       cloneNested(s.openZones),
+		Alarms:          cloneNested(s.alarms),
+		LastCommandAt:   maps.Clone(s.lastCommandAt),
+		Delays:          maps.Clone(s.delays),
+		Notifications:   make(map[int]map[NotificationEvent]bool, len(s.notifications)),
+		History:         make(map[int][]HistoryEntry, len(s.history)),
+		Faults:          make(map[int][]Fault, len(s.faults)),
+		NextFaultId:     s.nextFaultId,
+		Firmware:        maps.Clone(s.firmware),
+		FirmwareUpdates: maps.Clone(s.firmwareUpdates),
+		Commands:        slices.Collect(maps.Values(s.commands)),
+		NextCommandId:   s.nextCommandId,
+		Events:          slices.Clone(s.events),
+	}
+	for deviceId, settings := range s.notifications {
+		state.Notifications[deviceId] = maps.Clone(settings)
+	}
+	for deviceId, entries := range s.history {
+		state.History[deviceId] = slices.Clone(entries)
+	}
+	for deviceId, list := range s.faults {
+		state.Faults[deviceId] = slices.Clone(list)
+	}
+	slices.SortFunc(state.Commands, func(a, b Command) int { return cmp.Compare(a.Sequence, b.Sequence) })
+	return state
+}
+
+func (s *memoryStore) Restore(state StoreState) {
+	s.activeScenario = cloneOrEmpty(state.ActiveScenario)
+	s.versions = cloneOrEmpty(state.Versions)
+	s.changedAt = cloneOrEmpty(state.ChangedAt)
+	s.openZones = cloneNested(state.OpenZones)
+	s.alarms = cloneNested(state.Alarms)
+	s.exitDelays = map[int]inimcloud.ExitDelayView{}
+	s.lastCommandAt = cloneOrEmpty(state.LastCommandAt)
+	s.delays = cloneOrEmpty(state.Delays)
+	s.notifications = make(map[int]map[NotificationEvent]bool, len(state.Notifications))
+	for deviceId, settings := range state.Notifications {
+		s.notifications[deviceId] = maps.Clone(settings)
+	}
+	s.history = make(map[int][]HistoryEntry, len(state.History))
+	for deviceId, entries := range state.History {
+		s.history[deviceId] = slices.Clone(entries)
+	}
+	s.faults = make(map[int][]Fault, len(state.Faults))
+	for deviceId, list := range state.Faults {
+		s.faults[deviceId] = slices.Clone(list)
+	}
+	s.nextFaultId = max(state.NextFaultId, 1)
+	s.firmware = cloneOrEmpty(state.Firmware)
+	s.firmwareUpdates = cloneOrEmpty(state.FirmwareUpdates)
+	s.commands = make(map[string]Command, len(state.Commands))
+	for _, cmd := range state.Commands {
+		s.commands[cmd.CommandId] = cmd
+	}
+	s.nextCommandId = max(state.NextCommandId, 1)
+	s.events = append([]Event{}, state.Events...)
+}
+
+func setNested(m map[int]map[int]bool, outer, inner int, value bool) {
+	if m[outer] == nil {
+		m[outer] = map[int]bool{}
+	}
+	m[outer][inner] = value
+}
+
+func cloneNested(m map[int]map[int]bool) map[int]map[int]bool {
+	clone := make(map[int]map[int]bool, len(m))
+	for key, inner := range m {
+		clone[key] = maps.Clone(inner)
+	}
+	return clone
+}
+
+func cloneOrEmpty[K comparable, V any](m map[K]V) map[K]V {
+	if m == nil {
+		return map[K]V{}
+	}
+	return maps.Clone(m)
+}
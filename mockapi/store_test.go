@@ -0,0 +1,434 @@
+package main
+
+import (
+	"encoding/json"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/lacherogwu/ha-inim_cloud/mockapi/inimcloud"
+)
+
+// fakeStore keeps each device's state in one record instead of a map per
+// field, to check the handlers only rely on the Store interface.
+type fakeStore struct {
+	devices       []Device
+	state         map[int]*fakeDeviceState
+	nextFaultId   int
+	commands      []Command
+	nextCommandId int
+	events        []Event
+	sessions      []Session
+}
+
+type fakeDeviceState struct {
+	scenario       int
+	version        int
+	changedAt      time.Time
+	openZones      []int
+	alarms         []int
+	exitDelay      *inimcloud.ExitDelayView
+	lastCommandAt  time.Time
+	delays         *Delays
+	notifications  map[NotificationEvent]bool
+	history        []HistoryEntry
+	faults         []Fault
+	firmware       *string
+	firmwareUpdate *FirmwareUpdate
+}
+
+func newFakeStore(devices []Device, activeScenario map[int]int) Store {
+	s := &fakeStore{devices: devices, state: map[int]*fakeDeviceState{}, nextFaultId: 1, nextCommandId: 1}
+	for _, device := range devices {
+		s.state[device.DeviceId] = &fakeDeviceState{scenario: activeScenario[device.DeviceId]}
+	}
+	return s
+}
+
+func (s *fakeStore) of(deviceId int) *fakeDeviceState {
+	if s.state[deviceId] == nil {
+		s.state[deviceId] = &fakeDeviceState{}
+	}
+	return s.state[deviceId]
+}
+
+func (s *fakeStore) Devices() []Device {
+	return s.devices
+}
+
+func (s *fakeStore) Device(deviceId int) (Device, bool) {
+	i := slices.IndexFunc(s.devices, func(d Device) bool { return d.DeviceId == deviceId })
+	if i < 0 {
+		return Device{}, false
+	}
+	return s.devices[i], true
+}
+
+func (s *fakeStore) ActiveScenario(deviceId int) int {
+	return s.of(deviceId).scenario
+}
+
+func (s *fakeStore) SetActiveScenario(deviceId, scenarioId int) {
+	s.of(deviceId).scenario = scenarioId
+	s.MarkChanged(deviceId)
+}
+
+func (s *fakeStore) MarkChanged(deviceId int) {
+	s.of(deviceId).version++
+	s.of(deviceId).changedAt = clock.Now()
+}
+
+func (s *fakeStore) Version(deviceId int) int {
+	return s.of(deviceId).version
+}
+
+func (s *fakeStore) ChangedAt(deviceId int) time.Time {
+	return s.of(deviceId).changedAt
+}
+
+func (s *fakeStore) ZoneOpen(deviceId, zoneId int) bool {
+	return slices.Contains(s.of(deviceId).openZones, zoneId)
+}
+
+func (s *fakeStore) SetZoneOpen(deviceId, zoneId int, open bool) {
+	s.of(deviceId).openZones = toggle(s.of(deviceId).openZones, zoneId, open)
+}
+
+func (s *fakeStore) Alarm(deviceId, areaId int) bool {
+	return slices.Contains(s.of(deviceId).alarms, areaId)
+}
+
+func (s *fakeStore) SetAlarm(deviceId, areaId int, active bool) {
+	s.of(deviceId).alarms = toggle(s.of(deviceId).alarms, areaId, active)
+}
+
+func (s *fakeStore) ExitDelay(deviceId int) (inimcloud.ExitDelayView, bool) {
+	if pending := s.of(deviceId).exitDelay; pending != nil {
+		return *pending, true
+	}
+	return inimcloud.ExitDelayView{}, false
+}
+
+func (s *fakeStore) SetExitDelay(deviceId int, pending inimcloud.ExitDelayView) {
+	s.of(deviceId).exitDelay = &pending
+}
+
+func (s *fakeStore) ClearExitDelay(deviceId int) {
+	s.of(deviceId).exitDelay = nil
+}
+
+func (s *fakeStore) LastCommandAt(deviceId int) time.Time {
+	return s.of(deviceId).lastCommandAt
+}
+
+func (s *fakeStore) SetLastCommandAt(deviceId int, at time.Time) {
+	s.of(deviceId).lastCommandAt = at
+}
+
+func (s *fakeStore) Delays(deviceId int) (Delays, bool) {
+	if d := s.of(deviceId).delays; d != nil {
+		return *d, true
+	}
+	return Delays{}, false
+}
+
+func (s *fakeStore) SetDelays(deviceId int, d Delays) {
+	s.of(deviceId).delays = &d
+}
+
+func (s *fakeStore) NotificationSettings(deviceId int) map[NotificationEvent]bool {
+	return s.of(deviceId).notifications
+}
+
+func (s *fakeStore) SetNotificationSetting(deviceId int, event NotificationEvent, enabled bool) {
+	if s.of(deviceId).notifications == nil {
+		s.of(deviceId).notifications = map[NotificationEvent]bool{}
+	}
+	s.of(deviceId).notifications[event] = enabled
+}
+
+func (s *fakeStore) History(deviceId int) []HistoryEntry {
+	return s.of(deviceId).history
+}
+
+func (s *fakeStore) AppendHistory(deviceId int, entry HistoryEntry, limit int) {
+	entries := append(slices.Clone(s.of(deviceId).history), entry)
+	s.of(deviceId).history = entries[max(0, len(entries)-limit):]
+}
+
+func (s *fakeStore) Faults(deviceId int) []Fault {
+	return s.of(deviceId).faults
+}
+
+func (s *fakeStore) AddFault(deviceId int, fault Fault) Fault {
+	fault.FaultId = s.nextFaultId
+	s.nextFaultId++
+	s.of(deviceId).faults = append(s.of(deviceId).faults, fault)
+	return fault
+}
+
+func (s *fakeStore) RemoveFault(deviceId, faultId int) bool {
+	before := len(s.of(deviceId).faults)
+	s.of(deviceId).faults = slices.DeleteFunc(slices.Clone(s.of(deviceId).faults), func(f Fault) bool { return f.FaultId == faultId })
+	return len(s.of(deviceId).faults) < before
+}
+
+func (s *fakeStore) FirmwareVersion(deviceId int) (string, bool) {
+	if version := s.of(deviceId).firmware; version != nil {
+		return *version, true
+	}
+	return "", false
+}
+
+func (s *fakeStore) SetFirmwareVersion(deviceId int, version string) {
+	s.of(deviceId).firmware = &version
+}
+
+func (s *fakeStore) FirmwareUpdate(deviceId int) (FirmwareUpdate, bool) {
+	if update := s.of(deviceId).firmwareUpdate; update != nil {
+		return *update, true
+	}
+	return FirmwareUpdate{}, false
+}
+
+func (s *fakeStore) SetFirmwareUpdate(deviceId int, update FirmwareUpdate) {
+	s.of(deviceId).firmwareUpdate = &update
+}
+
+func (s *fakeStore) ClearFirmwareUpdate(deviceId int) {
+	s.of(deviceId).firmwareUpdate = nil
+}
+
+func (s *fakeStore) AddCommand(cmd Command) Command {
+	cmd.CommandId = strconv.Itoa(s.nextCommandId)
+	s.nextCommandId++
+	s.commands = append(s.commands, cmd)
+	return cmd
+}
+
+func (s *fakeStore) Command(commandId string) (Command, bool) {
+	i := slices.IndexFunc(s.commands, func(cmd Command) bool { return cmd.CommandId == commandId })
+	if i < 0 {
+		return Command{}, false
+	}
+	return s.commands[i], true
+}
+
+func (s *fakeStore) SetCommandStatus(commandId string, status CommandStatus) {
+	if i := slices.IndexFunc(s.commands, func(cmd Command) bool { return cmd.CommandId == commandId }); i >= 0 {
+		s.commands[i].Status = status
+	}
+}
+
+func (s *fakeStore) AppendEvent(event Event, limit int) {
+	s.events = append(s.events, event)
+	s.events = s.events[max(0, len(s.events)-limit):]
+}
+
+func (s *fakeStore) Events() []Event {
+	return s.events
+}
+
+func (s *fakeStore) Session(token string) (Session, bool) {
+	i := slices.IndexFunc(s.sessions, func(session Session) bool { return session.Token == token })
+	if i < 0 {
+		return Session{}, false
+	}
+	return s.sessions[i], true
+}
+
+func (s *fakeStore) PutSession(session Session) {
+	s.DeleteSession(session.Token)
+	s.sessions = append(s.sessions, session)
+}
+
+func (s *fakeStore) DeleteSession(token string) {
+	s.sessions = slices.DeleteFunc(s.sessions, func(session Session) bool { return session.Token == token })
+}
+
+func (s *fakeStore) Sessions() []Session {
+	return slices.Clone(s.sessions)
+}
+
+func (s *fakeStore) RestoreSessions(list []Session) {
+	s.sessions = slices.Clone(list)
+}
+
+func (s *fakeStore) Snapshot() StoreState {
+	state := StoreState{
+		ActiveScenario:  map[int]int{},
+		Versions:        map[int]int{},
+		ChangedAt:       map[int]time.Time{},
+		OpenZones:       map[int]map[int]bool{},
+		Alarms:          map[int]map[int]bool{},
+		LastCommandAt:   map[int]time.Time{},
+		Delays:          map[int]Delays{},
+		Notifications:   map[int]map[NotificationEvent]bool{},
+		History:         map[int][]HistoryEntry{},
+		Faults:          map[int][]Fault{},
+		NextFaultId:     s.nextFaultId,
+		Firmware:        map[int]string{},
+		FirmwareUpdates: map[int]FirmwareUpdate{},
+		Commands:        slices.Clone(s.commands),
+		NextCommandId:   s.nextCommandId,
+		Events:          slices.Clone(s.events),
+	}
+	for deviceId, device := range s.state {
+		state.ActiveScenario[deviceId] = device.scenario
+		state.Versions[deviceId] = device.version
+		state.ChangedAt[deviceId] = device.changedAt
+		for _, zoneId := range device.openZones {
+			setNested(state.OpenZones, deviceId, zoneId, true)
+		}
+		for _, areaId := range device.alarms {
+			setNested(state.Alarms, deviceId, areaId, true)
+		}
+		state.LastCommandAt[deviceId] = device.lastCommandAt
+		if device.delays != nil {
+			state.Delays[deviceId] = *device.delays
+		}
+		if device.notifications != nil {
+			state.Notifications[deviceId] = maps.Clone(device.notifications)
+		}
+		state.History[deviceId] = slices.Clone(device.history)
+		state.Faults[deviceId] = slices.Clone(device.faults)
+		if device.firmware != nil {
+			state.Firmware[deviceId] = *device.firmware
+		}
+		if device.firmwareUpdate != nil {
+			state.FirmwareUpdates[deviceId] = *device.firmwareUpdate
+		}
+	}
+	return state
+}
+
+func (s *fakeStore) Restore(state StoreState) {
+	s.state = map[int]*fakeDeviceState{}
+	for deviceId, scenarioId := range state.ActiveScenario {
+		s.of(deviceId).scenario = scenarioId
+	}
+	for deviceId, version := range state.Versions {
+		s.of(deviceId).version = version
+		s.of(deviceId).changedAt = state.ChangedAt[deviceId]
+	}
+	for deviceId, zones := range state.OpenZones {
+		for zoneId, open := range zones {
+			s.SetZoneOpen(deviceId, zoneId, open)
+		}
+	}
+	for deviceId, areas := range state.Alarms {
+		for areaId, active := range areas {
+			s.SetAlarm(deviceId, areaId, active)
+		}
+	}
+	for deviceId, at := range state.LastCommandAt {
+		s.SetLastCommandAt(deviceId, at)
+	}
+	for deviceId, d := range state.Delays {
+		s.SetDelays(deviceId, d)
+	}
+	for deviceId, settings := range state.Notifications {
+		s.of(deviceId).notifications = maps.Clone(settings)
+	}
+	for deviceId, entries := range state.History {
+		s.of(deviceId).history = slices.Clone(entries)
+	}
+	for deviceId, list := range state.Faults {
+		s.of(deviceId).faults = slices.Clone(list)
+	}
+	for deviceId, version := range state.Firmware {
+		s.SetFirmwareVersion(deviceId, version)
+	}
+	for deviceId, update := range state.FirmwareUpdates {
+		s.SetFirmwareUpdate(deviceId, update)
+	}
+	s.nextFaultId = max(state.NextFaultId, 1)
+	s.commands = slices.Clone(state.Commands)
+	s.nextCommandId = max(state.NextCommandId, 1)
+	s.events = slices.Clone(state.Events)
+}
+
+func toggle(ids []int, id int, on bool) []int {
+	ids = slices.DeleteFunc(ids, func(other int) bool { return other == id })
+	if on {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// storeSuite exercises the handlers that read and write the store.
+var storeSuite = map[string]func(t *testing.T, h http.Handler){
+	"activation": func(t *testing.T, h http.Handler) {
+		before := getDevice(t, h)
+		mustCall(t, h, inimcloud.MethodActivateScenario, activate(2), nil)
+		after := getDevice(t, h)
+		if after.ActiveScenario != 2 || after.Version != before.Version+1 {
+			t.Errorf("after activation: scenario %d version %d, want 2 and %d", after.ActiveScenario, after.Version, before.Version+1)
+		}
+	},
+	"zones and alarms": func(t *testing.T, h http.Handler) {
+		post(t, h, "/admin/devices/545002/zones/1/open", "")
+		post(t, h, "/admin/devices/545002/alarm", `{"Active":true}`)
+		device := getDevice(t, h)
+		if device.Zones[0].Status != inimcloud.ZoneOpen || !device.Alarm {
+			t.Errorf("zone status %d, alarm %v, want open and in alarm", device.Zones[0].Status, device.Alarm)
+		}
+	},
+	"events": func(t *testing.T, h http.Handler) {
+		mustCall(t, h, inimcloud.MethodActivateScenario, activate(2), nil)
+		data := struct{ Events []Event }{}
+		mustCall(t, h, inimcloud.MethodGetEventsLatest, nil, &data)
+		if len(data.Events) != 1 || data.Events[0].Type != EventScenarioChanged {
+			t.Errorf("events = %+v, want one ScenarioChanged", data.Events)
+		}
+	},
+	"faults": func(t *testing.T, h http.Handler) {
+		post(t, h, "/admin/devices/545002/faults", `{"Type": "LowBattery"}`)
+		post(t, h, "/admin/devices/545002/faults", `{"Type": "Tamper"}`)
+		mustCall(t, h, inimcloud.MethodAckFault, map[string]any{"DeviceId": testDevice, "FaultId": 1}, nil)
+		if list := getFaults(t, h); len(list) != 1 || list[0].FaultId != 2 {
+			t.Errorf("faults after the ack = %+v, want FaultId 2 only", list)
+		}
+	},
+	"sessions": func(t *testing.T, h http.Handler) {
+		token := login(t, h)
+		_, reply := callApi(t, h, inimcloud.Request{Method: inimcloud.MethodGetSessionInfo, Token: token})
+		info := struct{ ClientId string }{}
+		if err := json.Unmarshal(reply.Data, &info); err != nil || info.ClientId != "test" {
+			t.Errorf("GetSessionInfo = %+v, want the session of client test", reply)
+		}
+	},
+	"snapshot": func(t *testing.T, h http.Handler) {
+		saved := snapshot(t, h)
+		mustCall(t, h, inimcloud.MethodActivateScenario, activate(2), nil)
+		if rec := post(t, h, "/admin/restore", saved); rec.Code != http.StatusNoContent {
+			t.Fatalf("POST /admin/restore = %d %s", rec.Code, rec.Body)
+		}
+		if device := getDevice(t, h); device.ActiveScenario != 1 {
+			t.Errorf("restored scenario = %d, want 1", device.ActiveScenario)
+		}
+	},
+}
+
+func TestHandlersAgainstStores(t *testing.T) {
+	stores := map[string]func([]Device, map[int]int) Store{
+		"memory": NewMemoryStore,
+		"fake":   newFakeStore,
+	}
+	for storeName, newStore := range stores {
+		for name, test := range storeSuite {
+			t.Run(storeName+"/"+name, func(t *testing.T) {
+				h := newTestMux(t)
+				stateMu.Lock()
+				sessionsMu.Lock()
+				store = newStore(defaultDevices, map[int]int{testDevice: 1})
+				sessionsMu.Unlock()
+				stateMu.Unlock()
+				test(t, h)
+			})
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// HandleDeviceStream serves the devices as newline-delimited JSON, one
+// device per line, flushing after each so large accounts can be consumed
+// incrementally. It stops as soon as the client goes away.
+func HandleDeviceStream(w http.ResponseWriter, r *http.Request) {
+	stateMu.Lock()
+	views := DeviceViews()
+	stateMu.Unlock()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+
+	flusher, _ := w.(http.Flusher)
+	encoder := json.NewEncoder(w)
+	for _, view := range views {
+		if r.Context().Err() != nil {
+			return
+		}
+		if err := encoder.Encode(view); err != nil {
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
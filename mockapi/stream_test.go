@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lacherogwu/ha-inim_cloud/mockapi/inimcloud"
+)
+
+// manyDevices returns copies of the built-in device numbered from 1.
+func manyDevices(n int) ([]Device, map[int]int) {
+	devices := make([]Device, 0, n)
+	active := map[int]int{}
+	for i := range n {
+		device := defaultDevices[0]
+		device.DeviceId = i + 1
+		devices = append(devices, device)
+		active[device.DeviceId] = 1
+	}
+	return devices, active
+}
+
+func TestDeviceStreamOneDevicePerLine(t *testing.T) {
+	resetState()
+	store = NewMemoryStore(manyDevices(3))
+	rec := httptest.NewRecorder()
+	NewMux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/devices/stream", nil))
+
+	if ct := rec.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if !rec.Flushed {
+		t.Error("stream was not flushed")
+	}
+	scanner := bufio.NewScanner(rec.Body)
+	ids := []int{}
+	for scanner.Scan() {
+		view := inimcloud.DeviceView{}
+		if err := json.Unmarshal(scanner.Bytes(), &view); err != nil {
+			t.Fatalf("line %q: %v", scanner.Text(), err)
+		}
+		ids = append(ids, view.DeviceId)
+	}
+	if len(ids) != 3 || ids[0] != 1 || ids[2] != 3 {
+		t.Errorf("streamed devices %v, want 1 to 3", ids)
+	}
+}
+
+func TestDeviceStreamStopsWhenClientLeaves(t *testing.T) {
+	resetState()
+	store = NewMemoryStore(manyDevices(3))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	rec := httptest.NewRecorder()
+	NewMux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/devices/stream", nil).WithContext(ctx))
+	if rec.Body.Len() != 0 {
+		t.Errorf("streamed %q to a client that had gone", rec.Body)
+	}
+}
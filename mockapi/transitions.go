@@ -0,0 +1,36 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+)
+
+// Some panels refuse certain scenario changes, e.g. STAY to ARM without
+// disarming first. A transition policy lists, per device, the scenarios
+// that may follow each scenario. Devices without a policy, and scenarios
+// without an entry in their device's policy, allow any transition.
+var transitionPolicyFile = flag.String("transition-policy", "", "JSON file mapping device id to allowed scenario transitions (from id to list of to ids)")
+
+var transitionPolicy = map[int]map[int][]int{}
+
+func LoadTransitionPolicy(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &transitionPolicy)
+}
+
+func TransitionAllowed(deviceId, from, to int) bool {
+	allowed, ok := transitionPolicy[deviceId][from]
+	if !ok || from == to {
+		return true
+	}
+	for _, scenarioId := range allowed {
+		if scenarioId == to {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lacherogwu/ha-inim_cloud/mockapi/inimcloud"
+)
+
+func TestTransitionPolicy(t *testing.T) {
+	h := newTestMux(t)
+	path := filepath.Join(t.TempDir(), "policy.json")
+	// From DISARM anything goes; from STAY only back to DISARM.
+	if err := os.WriteFile(path, []byte(`{"545002": {"2": [1]}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadTransitionPolicy(path); err != nil {
+		t.Fatal(err)
+	}
+
+	mustCall(t, h, inimcloud.MethodActivateScenario, activate(2), nil)
+	if status := callStatus(t, h, inimcloud.MethodActivateScenario, activate(0)); status != int(ErrTransitionNotAllowed) {
+		t.Errorf("STAY to ARM = %d, want %d", status, ErrTransitionNotAllowed)
+	}
+	if got := getDevice(t, h).ActiveScenario; got != 2 {
+		t.Errorf("scenario after a refused transition = %d, want 2", got)
+	}
+	mustCall(t, h, inimcloud.MethodActivateScenario, activate(2), nil)
+	mustCall(t, h, inimcloud.MethodActivateScenario, activate(1), nil)
+	mustCall(t, h, inimcloud.MethodActivateScenario, activate(0), nil)
+}
+
+func TestTransitionAllowedWithoutPolicy(t *testing.T) {
+	resetState()
+	for _, to := range []int{0, 1, 2} {
+		if !TransitionAllowed(testDevice, 2, to) {
+			t.Errorf("2 -> %d refused without a policy", to)
+		}
+	}
+}
+
+func TestLoadTransitionPolicyInvalid(t *testing.T) {
+	resetState()
+	path := filepath.Join(t.TempDir(), "policy.json")
+	os.WriteFile(path, []byte(`{"545002": [1]}`), 0o644)
+	if err := LoadTransitionPolicy(path); err == nil {
+		t.Error("LoadTransitionPolicy accepted an invalid policy")
+	}
+}
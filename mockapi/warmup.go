@@ -0,0 +1,40 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+	"time"
+)
+
+// A freshly started cloud is sluggish for a while. During -warmup every
+// request is delayed, starting at -warmup-latency and shrinking linearly to
+// nothing once the warmup has elapsed.
+var (
+	warmup        = flag.Duration("warmup", 0, "duration of the slow-start period after startup")
+	warmupLatency = flag.Duration("warmup-latency", 2*time.Second, "latency added at startup, decreasing to zero over -warmup")
+)
+
+// WarmupLatency returns the latency to add to a request at now for a server
+// started at start.
+func WarmupLatency(start, now time.Time) time.Duration {
+	elapsed := now.Sub(start)
+	if *warmup <= 0 || elapsed >= *warmup {
+		return 0
+	}
+	remaining := float64(*warmup-elapsed) / float64(*warmup)
+	return time.Duration(remaining * float64(*warmupLatency))
+}
+
+// Warmup wraps next with the slow-start period, which is a no-op unless
+// -warmup is set.
+func Warmup(next http.HandlerFunc) http.HandlerFunc {
+	if *warmup <= 0 {
+		return next
+	}
+
+	start := clock.Now()
+	return func(w http.ResponseWriter, r *http.Request) {
+		clock.Sleep(WarmupLatency(start, clock.Now()))
+		next(w, r)
+	}
+}
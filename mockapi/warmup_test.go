@@ -0,0 +1,51 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lacherogwu/ha-inim_cloud/mockapi/inimcloud"
+)
+
+func TestWarmupLatency(t *testing.T) {
+	setFlag(t, warmup, 10*time.Second)
+	setFlag(t, warmupLatency, 2*time.Second)
+	start := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		elapsed time.Duration
+		want    time.Duration
+	}{
+		{0, 2 * time.Second},
+		{5 * time.Second, time.Second},
+		{10 * time.Second, 0},
+		{time.Minute, 0},
+	}
+	for _, tt := range tests {
+		if got := WarmupLatency(start, start.Add(tt.elapsed)); got != tt.want {
+			t.Errorf("WarmupLatency after %v = %v, want %v", tt.elapsed, got, tt.want)
+		}
+	}
+}
+
+func TestWarmupDelaysEarlyRequests(t *testing.T) {
+	fake := useFakeClock(t)
+	setFlag(t, warmup, 10*time.Second)
+	setFlag(t, warmupLatency, 2*time.Second)
+	h := newTestMux(t)
+
+	mustCall(t, h, inimcloud.MethodGetSystemTime, nil, nil)
+	if slept := fake.Slept(); slept != 2*time.Second {
+		t.Errorf("first request delayed %v, want 2s", slept)
+	}
+	// The first request's delay already moved the clock on by 2s.
+	fake.Advance(3 * time.Second)
+	mustCall(t, h, inimcloud.MethodGetSystemTime, nil, nil)
+	if slept := fake.Slept(); slept != time.Second {
+		t.Errorf("request halfway through the warmup delayed %v, want 1s", slept)
+	}
+	fake.Advance(10 * time.Second)
+	mustCall(t, h, inimcloud.MethodGetSystemTime, nil, nil)
+	if slept := fake.Slept(); slept != 0 {
+		t.Errorf("request after the warmup delayed %v", slept)
+	}
+}
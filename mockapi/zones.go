@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/lacherogwu/ha-inim_cloud/mockapi/inimcloud"
+)
+
+// GetZoneConfig and GetDeviceStatus split what GetDevicesExtended reports
+// about a device in two: the zones as configured, with the Type an
+// integration picks a device class from, and the live state alone, which is
+// all a poll needs once the configuration is known.
+
+func HandleGetZoneConfig(w http.ResponseWriter, reqData *inimcloud.Request) {
+	v := validateParams(reqData.Params)
+	deviceId := v.Int("DeviceId")
+	if !v.Check(w) {
+		return
+	}
+	stateMu.Lock()
+	defer stateMu.Unlock()
+
+	device, found := store.Device(deviceId)
+	if !found {
+		WriteStatus(w, ErrUnknownDevice, "Device not found")
+		return
+	}
+	WriteJson(w, map[string]any{
+		"DeviceId": deviceId,
+		"Zones":    device.Zones,
+	})
+}
+
+// ZoneStatus is the live state of a zone, without its configuration.
+type ZoneStatus struct {
+	ZoneId int `json:"ZoneId"`
+	Status int `json:"Status"`
+}
+
+func HandleGetDeviceStatus(w http.ResponseWriter, reqData *inimcloud.Request) {
+	v := validateParams(reqData.Params)
+	deviceId := v.Int("DeviceId")
+	if !v.Check(w) {
+		return
+	}
+	stateMu.Lock()
+	defer stateMu.Unlock()
+
+	device, found := store.Device(deviceId)
+	if !found {
+		WriteStatus(w, ErrUnknownDevice, "Device not found")
+		return
+	}
+	zones := make([]ZoneStatus, 0, len(device.Zones))
+	for _, zone := range ZoneViews(device) {
+		zones = append(zones, ZoneStatus{ZoneId: zone.ZoneId, Status: zone.Status})
+	}
+	WriteJson(w, map[string]any{
+		"DeviceId":       deviceId,
+		"ActiveScenario": VisibleScenario(deviceId),
+		"Version":        store.Version(deviceId),
+		"Alarm":          InAlarm(device),
+		"ExitDelay":      PendingExitDelay(deviceId),
+		"Zones":          zones,
+	})
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/lacherogwu/ha-inim_cloud/mockapi/inimcloud"
+)
+
+func TestZoneConfigAndStatusAreSplit(t *testing.T) {
+	h := newTestMux(t)
+	post(t, h, "/admin/devices/545002/zones/1/open", "")
+	params := map[string]any{"DeviceId": testDevice}
+
+	config := struct{ Zones []inimcloud.Zone }{}
+	mustCall(t, h, inimcloud.MethodGetZoneConfig, params, &config)
+	if len(config.Zones) != 1 || config.Zones[0].Type != 1 || config.Zones[0].Name != "Front door" {
+		t.Errorf("zone config = %+v, want the front door with its type", config.Zones)
+	}
+
+	status := struct {
+		ActiveScenario int
+		Zones          []map[string]json.RawMessage
+	}{}
+	mustCall(t, h, inimcloud.MethodGetDeviceStatus, params, &status)
+	if status.ActiveScenario != 1 || len(status.Zones) != 1 || string(status.Zones[0]["Status"]) != "1" {
+		t.Fatalf("device status = %+v, want scenario 1 and the front door open", status)
+	}
+	for _, key := range []string{"Type", "Name", "Areas"} {
+		if _, ok := status.Zones[0][key]; ok {
+			t.Errorf("device status reports the zone's %s", key)
+		}
+	}
+
+	for _, method := range []inimcloud.Method{inimcloud.MethodGetZoneConfig, inimcloud.MethodGetDeviceStatus} {
+		if got := callStatus(t, h, method, map[string]any{"DeviceId": 1}); got != int(ErrUnknownDevice) {
+			t.Errorf("%s of an unknown device = %d, want %d", method, got, ErrUnknownDevice)
+		}
+	}
+}